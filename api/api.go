@@ -0,0 +1,96 @@
+// Package api contains the types served by the ugit JSON API at /api/v1, along with a typed client
+package api
+
+import "time"
+
+// Repo is a repository
+type Repo struct {
+	Name          string   `json:"name"`
+	Description   string   `json:"description"`
+	Private       bool     `json:"private"`
+	Tags          []string `json:"tags"`
+	DefaultBranch string   `json:"default_branch"`
+	// Mirror is the upstream URL if the repo is a pull mirror
+	Mirror string `json:"mirror,omitempty"`
+}
+
+// Tag is a git tag
+type Tag struct {
+	Name       string    `json:"name"`
+	Annotation string    `json:"annotation"`
+	Signature  string    `json:"signature,omitempty"`
+	When       time.Time `json:"when"`
+}
+
+// TreeEntry is a file or directory in a tree
+type TreeEntry struct {
+	Path  string `json:"path"`
+	IsDir bool   `json:"is_dir"`
+	Mode  string `json:"mode"`
+	Size  string `json:"size"`
+}
+
+// File is the content of a file at a ref
+type File struct {
+	Path    string `json:"path"`
+	Ref     string `json:"ref"`
+	Content []byte `json:"content"`
+}
+
+// Commit is a git commit
+type Commit struct {
+	SHA       string    `json:"sha"`
+	Parents   []string  `json:"parents"`
+	Message   string    `json:"message"`
+	Signature string    `json:"signature,omitempty"`
+	Author    string    `json:"author"`
+	Email     string    `json:"email"`
+	When      time.Time `json:"when"`
+
+	// Only set for a single commit
+	Stats *CommitStats `json:"stats,omitempty"`
+	Files []CommitFile `json:"files,omitempty"`
+}
+
+// CommitStats is the stats of a commit
+type CommitStats struct {
+	Changed   int `json:"changed"`
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+}
+
+// CommitFile is a file changed in a commit
+type CommitFile struct {
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+	Action string `json:"action"`
+	Patch  string `json:"patch"`
+}
+
+// CommitPage is a page of commits
+type CommitPage struct {
+	Commits []Commit `json:"commits"`
+	// Next is the cursor for the next page, empty if there are no more commits
+	Next string `json:"next,omitempty"`
+}
+
+// CommitOptions filter and paginate commits
+type CommitOptions struct {
+	Path   string
+	Author string
+	After  string
+	Limit  int
+}
+
+// GrepResult is a search result
+type GrepResult struct {
+	File      string `json:"file"`
+	StartLine int    `json:"start_line"`
+	Line      int    `json:"line"`
+	Content   string `json:"content"`
+}
+
+// Error is the body of an unsuccessful response
+type Error struct {
+	Message string `json:"error"`
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Client is a client for the ugit JSON API
+type Client struct {
+	baseURL  string
+	http     *http.Client
+	username string
+	token    string
+}
+
+// ClientOption configures a Client
+type ClientOption func(*Client)
+
+// WithHTTPClient sets the http.Client used for requests
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *Client) {
+		c.http = client
+	}
+}
+
+// WithToken authenticates requests with an access token, allowing access to private repos
+func WithToken(username, token string) ClientOption {
+	return func(c *Client) {
+		c.username = username
+		c.token = token
+	}
+}
+
+// New returns a new Client for the ugit instance at baseURL, e.g. https://git.example.com
+func New(baseURL string, opts ...ClientOption) *Client {
+	c := &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// StatusError is returned when the API responds with an unsuccessful status
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (s StatusError) Error() string {
+	return fmt.Sprintf("ugit api: %d %s", s.StatusCode, s.Message)
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, v any) error {
+	u := c.baseURL + "/api/v1" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.SetBasicAuth(c.username, c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr Error
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return StatusError{StatusCode: resp.StatusCode, Message: apiErr.Message}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// repoPath escapes each element and joins them under a repo, repo names may be in a namespace, e.g. group/repo
+func repoPath(repo string, elem ...string) string {
+	p := "/repos"
+	for _, e := range append([]string{repo}, elem...) {
+		for _, part := range strings.Split(e, "/") {
+			p += "/" + url.PathEscape(part)
+		}
+	}
+	return p
+}
+
+// Repos returns all repos visible to the client
+func (c *Client) Repos(ctx context.Context) ([]Repo, error) {
+	var repos []Repo
+	if err := c.get(ctx, "/repos", nil, &repos); err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
+
+// Repo returns a single repo
+func (c *Client) Repo(ctx context.Context, repo string) (Repo, error) {
+	var r Repo
+	if err := c.get(ctx, repoPath(repo), nil, &r); err != nil {
+		return Repo{}, err
+	}
+	return r, nil
+}
+
+// Branches returns the branches of a repo, default branch first
+func (c *Client) Branches(ctx context.Context, repo string) ([]string, error) {
+	var branches []string
+	if err := c.get(ctx, repoPath(repo, "branches"), nil, &branches); err != nil {
+		return nil, err
+	}
+	return branches, nil
+}
+
+// Tags returns the tags of a repo, newest first
+func (c *Client) Tags(ctx context.Context, repo string) ([]Tag, error) {
+	var tags []Tag
+	if err := c.get(ctx, repoPath(repo, "tags"), nil, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// Tree returns the entries of a directory at a ref, dirs first
+func (c *Client) Tree(ctx context.Context, repo, ref, path string) ([]TreeEntry, error) {
+	var entries []TreeEntry
+	if err := c.get(ctx, repoPath(repo, "tree", ref, path), nil, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// File returns the content of a file at a ref
+func (c *Client) File(ctx context.Context, repo, ref, path string) (File, error) {
+	var file File
+	if err := c.get(ctx, repoPath(repo, "file", ref, path), nil, &file); err != nil {
+		return File{}, err
+	}
+	return file, nil
+}
+
+// Commits returns a page of commits reachable from ref, newest first
+func (c *Client) Commits(ctx context.Context, repo, ref string, opts CommitOptions) (CommitPage, error) {
+	query := make(url.Values)
+	if opts.Path != "" {
+		query.Set("path", opts.Path)
+	}
+	if opts.Author != "" {
+		query.Set("author", opts.Author)
+	}
+	if opts.After != "" {
+		query.Set("after", opts.After)
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	var page CommitPage
+	if err := c.get(ctx, repoPath(repo, "commits", ref), query, &page); err != nil {
+		return CommitPage{}, err
+	}
+	return page, nil
+}
+
+// Commit returns a single commit, including its stats and changed files
+func (c *Client) Commit(ctx context.Context, repo, sha string) (Commit, error) {
+	var commit Commit
+	if err := c.get(ctx, repoPath(repo, "commit", sha), nil, &commit); err != nil {
+		return Commit{}, err
+	}
+	return commit, nil
+}
+
+// Search searches the default branch of a repo
+func (c *Client) Search(ctx context.Context, repo, q string) ([]GrepResult, error) {
+	var results []GrepResult
+	if err := c.get(ctx, repoPath(repo, "search"), url.Values{"q": {q}}, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
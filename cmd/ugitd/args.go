@@ -6,6 +6,8 @@ import (
 	"log/slog"
 	"strings"
 
+	"go.jolheiser.com/ugit/internal/git"
+
 	"github.com/peterbourgon/ff/v3"
 	"github.com/peterbourgon/ff/v3/ffyaml"
 )
@@ -17,21 +19,33 @@ type cliArgs struct {
 	Meta        metaArgs
 	Profile     profileArgs
 	Log         logArgs
+	Hooks       hookArgs
+	Mirror      mirrorArgs
+	Signing     signingArgs
 	ShowPrivate bool
+	Admins      []string
 }
 
 type sshArgs struct {
 	Enable         bool
 	AuthorizedKeys string
+	UserKeys       string
 	CloneURL       string
 	Port           int
 	HostKey        string
 }
 
 type httpArgs struct {
-	Enable   bool
-	CloneURL string
-	Port     int
+	Enable        bool
+	CloneURL      string
+	Port          int
+	AccessTokens  []accessToken
+	SessionSecret string
+}
+
+type accessToken struct {
+	User  string
+	Token string
 }
 
 type metaArgs struct {
@@ -50,6 +64,21 @@ type profileLink struct {
 	URL  string
 }
 
+type hookArgs struct {
+	Push  []string
+	Fetch []string
+}
+
+type mirrorArgs struct {
+	Credentials map[string]git.Credential
+}
+
+type signingArgs struct {
+	GPGKeys        []string
+	AllowedSigners string
+	AuthorizedKeys bool
+}
+
 type logArgs struct {
 	Level slog.Level
 	JSON  bool
@@ -80,6 +109,9 @@ func parseArgs(args []string) (c cliArgs, e error) {
 		Log: logArgs{
 			Level: slog.LevelError,
 		},
+		Mirror: mirrorArgs{
+			Credentials: make(map[string]git.Credential),
+		},
 	}
 
 	fs.Func("log.level", "Logging level", func(s string) error {
@@ -102,14 +134,66 @@ func parseArgs(args []string) (c cliArgs, e error) {
 	fs.BoolVar(&c.Log.JSON, "log.json", c.Log.JSON, "Print logs in JSON(L) format")
 	fs.StringVar(&c.RepoDir, "repo-dir", c.RepoDir, "Path to directory containing repositories")
 	fs.BoolVar(&c.ShowPrivate, "show-private", c.ShowPrivate, "Show private repos in web interface")
+	fs.Func("admins", "User(s) with admin access to every repo", func(s string) error {
+		c.Admins = append(c.Admins, s)
+		return nil
+	})
 	fs.BoolVar(&c.SSH.Enable, "ssh.enable", c.SSH.Enable, "Enable SSH server")
 	fs.StringVar(&c.SSH.AuthorizedKeys, "ssh.authorized-keys", c.SSH.AuthorizedKeys, "Path to authorized_keys")
+	fs.StringVar(&c.SSH.UserKeys, "ssh.user-keys", c.SSH.UserKeys, "Path to authorized_keys for non-admin users, named by key comment")
 	fs.StringVar(&c.SSH.CloneURL, "ssh.clone-url", c.SSH.CloneURL, "SSH clone URL base")
 	fs.IntVar(&c.SSH.Port, "ssh.port", c.SSH.Port, "SSH port")
 	fs.StringVar(&c.SSH.HostKey, "ssh.host-key", c.SSH.HostKey, "SSH host key (created if it doesn't exist)")
 	fs.BoolVar(&c.HTTP.Enable, "http.enable", c.HTTP.Enable, "Enable HTTP server")
 	fs.StringVar(&c.HTTP.CloneURL, "http.clone-url", c.HTTP.CloneURL, "HTTP clone URL base")
 	fs.IntVar(&c.HTTP.Port, "http.port", c.HTTP.Port, "HTTP port")
+	fs.StringVar(&c.HTTP.SessionSecret, "http.session-secret", c.HTTP.SessionSecret, "Secret for signing login sessions (random if unset, logging everyone out on restart)")
+	fs.Func("http.access-tokens", "Access token(s) for HTTP basic auth, in the form user,token", func(s string) error {
+		parts := strings.SplitN(s, ",", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid access token for %q", parts[0])
+		}
+		c.HTTP.AccessTokens = append(c.HTTP.AccessTokens, accessToken{
+			User:  parts[0],
+			Token: parts[1],
+		})
+		return nil
+	})
+	fs.Func("hooks.push", "Command(s) to run after a successful SSH push", func(s string) error {
+		c.Hooks.Push = append(c.Hooks.Push, s)
+		return nil
+	})
+	fs.Func("hooks.fetch", "Command(s) to run after a successful SSH fetch", func(s string) error {
+		c.Hooks.Fetch = append(c.Hooks.Fetch, s)
+		return nil
+	})
+	fs.Func("mirror.credentials", "Credential(s) for push mirrors, in the form name,username,password", func(s string) error {
+		parts := strings.SplitN(s, ",", 3)
+		if len(parts) != 3 || parts[0] == "" {
+			return fmt.Errorf("invalid mirror credential for %q", parts[0])
+		}
+		c.Mirror.Credentials[parts[0]] = git.Credential{
+			Username: parts[1],
+			Password: parts[2],
+		}
+		return nil
+	})
+	fs.Func("mirror.ssh-keys", "SSH key(s) for push mirrors, in the form name,path", func(s string) error {
+		parts := strings.SplitN(s, ",", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid mirror SSH key for %q", parts[0])
+		}
+		c.Mirror.Credentials[parts[0]] = git.Credential{
+			SSHKey: parts[1],
+		}
+		return nil
+	})
+	fs.Func("signing.gpg-keys", "Path(s) to armored OpenPGP public keys trusted to sign commits and tags", func(s string) error {
+		c.Signing.GPGKeys = append(c.Signing.GPGKeys, s)
+		return nil
+	})
+	fs.StringVar(&c.Signing.AllowedSigners, "signing.allowed-signers", c.Signing.AllowedSigners, "Path to SSH allowed signers trusted to sign commits and tags")
+	fs.BoolVar(&c.Signing.AuthorizedKeys, "signing.authorized-keys", c.Signing.AuthorizedKeys, "Trust the SSH keys in ssh.authorized-keys and ssh.user-keys to sign commits and tags")
 	fs.StringVar(&c.Meta.Title, "meta.title", c.Meta.Title, "App title")
 	fs.StringVar(&c.Meta.Description, "meta.description", c.Meta.Description, "App description")
 	fs.StringVar(&c.Profile.Username, "profile.username", c.Profile.Username, "Username for index page")
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -12,6 +14,7 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/httplog/v2"
@@ -23,9 +26,12 @@ import (
 )
 
 func main() {
-	if len(os.Args) > 1 && os.Args[1] == "pre-receive-hook" {
-		preReceive()
-		return
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "pre-receive-hook":
+			preReceive()
+			return
+		}
 	}
 
 	args, err := parseArgs(os.Args[1:])
@@ -63,13 +69,44 @@ func main() {
 		panic(err)
 	}
 
+	// Index any repos that were created or changed outside of ugit since the last run
+	go indexRepos(args.RepoDir)
+
+	catalog := git.NewCatalog(args.RepoDir)
+	go syncMirrors(catalog)
+	pushMirrors := git.NewPushMirrorQueue(args.Mirror.Credentials)
+	go pushMirrors.Run(context.Background())
+	webhooks := git.NewWebhookQueue()
+	go webhooks.Run(context.Background())
+	lfs, err := git.NewLFSAuth(args.HTTP.CloneURL)
+	if err != nil {
+		panic(err)
+	}
+
+	signing := git.SigningKeys{
+		GPGKeys:        args.Signing.GPGKeys,
+		AllowedSigners: args.Signing.AllowedSigners,
+	}
+	if args.Signing.AuthorizedKeys {
+		signing.AuthorizedKeys = []string{args.SSH.AuthorizedKeys, args.SSH.UserKeys}
+	}
+
 	if args.SSH.Enable {
 		sshSettings := ssh.Settings{
 			AuthorizedKeys: args.SSH.AuthorizedKeys,
+			UserKeys:       args.SSH.UserKeys,
+			Admins:         args.Admins,
 			CloneURL:       args.SSH.CloneURL,
 			Port:           args.SSH.Port,
 			HostKey:        args.SSH.HostKey,
 			RepoDir:        args.RepoDir,
+			PushHooks:      args.Hooks.Push,
+			FetchHooks:     args.Hooks.Fetch,
+			Catalog:        catalog,
+			PushMirrors:    pushMirrors,
+			Webhooks:       webhooks,
+			LFS:            lfs,
+			Signing:        signing,
 		}
 		sshSrv, err := ssh.New(sshSettings)
 		if err != nil {
@@ -93,7 +130,15 @@ func main() {
 			Username: args.Profile.Username,
 			Email:    args.Profile.Email,
 		},
-		ShowPrivate: args.ShowPrivate,
+		ShowPrivate:   args.ShowPrivate,
+		Admins:        args.Admins,
+		SessionSecret: []byte(args.HTTP.SessionSecret),
+		Catalog:       catalog,
+		PushMirrors:   pushMirrors,
+		Webhooks:      webhooks,
+		PushHooks:     args.Hooks.Push,
+		LFS:           lfs,
+		Signing:       signing,
 	}
 	for _, link := range args.Profile.Links {
 		httpSettings.Profile.Links = append(httpSettings.Profile.Links, http.Link{
@@ -101,6 +146,12 @@ func main() {
 			URL:  link.URL,
 		})
 	}
+	for _, token := range args.HTTP.AccessTokens {
+		httpSettings.AccessTokens = append(httpSettings.AccessTokens, http.AccessToken{
+			User:  token.User,
+			Token: token.Token,
+		})
+	}
 	if args.HTTP.Enable {
 		httpSrv := http.New(httpSettings)
 		go func() {
@@ -116,6 +167,45 @@ func main() {
 	<-ch
 }
 
+func indexRepos(repoDir string) {
+	if err := git.WalkRepos(repoDir, func(name string) error {
+		repo, err := git.NewRepo(repoDir, name)
+		if err != nil {
+			slog.Error("could not open repo", "repo", name, "error", err)
+			return nil
+		}
+		if err := repo.UpdateSearchIndex(); err != nil {
+			slog.Error("could not update search index", "repo", repo.Name(), "error", err)
+		}
+		return nil
+	}); err != nil {
+		slog.Error("could not read repo dir", "error", err)
+	}
+}
+
+// mirrorCheckInterval is how often pull mirrors are checked for whether they're due to sync
+var mirrorCheckInterval = time.Minute
+
+// syncMirrors periodically syncs every pull mirror whose interval has elapsed
+func syncMirrors(catalog *git.Catalog) {
+	for {
+		entries, err := catalog.Repos()
+		if err != nil {
+			slog.Error("could not list repos", "error", err)
+		}
+		for _, entry := range entries {
+			if !entry.Repo.MirrorDue() {
+				continue
+			}
+			if err := entry.Repo.SyncMirror(context.Background()); err != nil {
+				slog.Error("could not sync mirror", "repo", entry.Repo.Name(), "error", err)
+			}
+			catalog.Invalidate(entry.Repo.Name())
+		}
+		time.Sleep(mirrorCheckInterval)
+	}
+}
+
 func requiredFS(repoDir string) error {
 	if err := os.MkdirAll(repoDir, os.ModePerm); err != nil {
 		return err
@@ -133,8 +223,16 @@ func requiredFS(repoDir string) error {
 	if err := os.MkdirAll(fp, os.ModePerm); err != nil {
 		return err
 	}
-	fp = filepath.Join(fp, "pre-receive")
 
+	if err := writeHook(bin, filepath.Join(fp, "pre-receive"), "pre-receive-hook"); err != nil {
+		return err
+	}
+	// Everything ugit does after a push happens in the server, post-receive only runs the .d directory
+	return writeHook(bin, filepath.Join(fp, "post-receive"), "")
+}
+
+// writeHook writes a hook that runs ugitd, unless subcommand is empty, and then any executables in the hook's .d directory
+func writeHook(bin, fp, subcommand string) error {
 	if err := os.MkdirAll(fp+".d", os.ModePerm); err != nil {
 		return err
 	}
@@ -144,22 +242,38 @@ func requiredFS(repoDir string) error {
 		return err
 	}
 	fmt.Fprintln(fi, "#!/usr/bin/env bash")
-	fmt.Fprintf(fi, "%s pre-receive-hook\n", bin)
+	// Hooks receive ref updates on stdin, which is needed by ugitd and every hook in the .d directory
+	fmt.Fprintln(fi, `stdin="$(cat)"`)
+	if subcommand != "" {
+		fmt.Fprintf(fi, "%s %s <<< \"${stdin}\" || exit $?\n", bin, subcommand)
+	}
 	fmt.Fprintf(fi, `for hook in %s.d/*; do
 	test -x "${hook}" && test -f "${hook}" || continue
-	"${hook}"
+	"${hook}" <<< "${stdin}" || exit $?
 done`, fp)
 	fi.Close()
 
 	return os.Chmod(fp, 0o755)
 }
 
-func preReceive() {
+// hookRepo returns the repo a hook is running for
+func hookRepo() *git.Repo {
 	repoDir, ok := os.LookupEnv("UGIT_REPODIR")
 	if !ok {
 		panic("UGIT_REPODIR is not set")
 	}
+	name, ok := os.LookupEnv("UGIT_REPO")
+	if !ok {
+		panic("UGIT_REPO is not set")
+	}
+	repo, err := git.NewRepo(repoDir, name)
+	if err != nil {
+		panic(err)
+	}
+	return repo
+}
 
+func preReceive() {
 	opts := make([]*packp.Option, 0)
 	if pushCount, err := strconv.Atoi(os.Getenv("GIT_PUSH_OPTION_COUNT")); err == nil {
 		for idx := range pushCount {
@@ -174,11 +288,67 @@ func preReceive() {
 		}
 	}
 
-	repo, err := git.NewRepo(filepath.Dir(repoDir), filepath.Base(repoDir))
-	if err != nil {
-		panic(err)
+	repo := hookRepo()
+	var rejected bool
+	if err := repo.CheckRefUpdates(context.Background(), hookPusher(), hookSigningKeys(), hookRefUpdates(), func(_ git.RefUpdate, perr git.ProtectionError) {
+		rejected = true
+		fmt.Fprintf(os.Stderr, "error: %v\n", perr)
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "error: could not check protected refs: %v\n", err)
+		os.Exit(1)
+	}
+	if rejected {
+		os.Exit(1)
+	}
+
+	if err := git.HandlePushOptions(repo, hookPusher().Access, opts); err != nil {
+		if !errors.Is(err, git.ErrPushOptionsDenied) {
+			panic(err)
+		}
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+}
+
+// hookRefUpdates reads the ref updates a hook receives on stdin
+func hookRefUpdates() []git.RefUpdate {
+	var refs []git.RefUpdate
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) != 3 {
+			continue
+		}
+		refs = append(refs, git.RefUpdate{
+			Old:  parts[0],
+			New:  parts[1],
+			Name: parts[2],
+		})
 	}
-	if err := git.HandlePushOptions(repo, opts); err != nil {
+	if err := scanner.Err(); err != nil {
 		panic(err)
 	}
+	return refs
+}
+
+// hookSigningKeys returns the keys signatures are verified against, as passed to the hook by the protocol
+func hookSigningKeys() git.SigningKeys {
+	list := func(key string) []string {
+		return strings.FieldsFunc(os.Getenv(key), func(r rune) bool { return r == filepath.ListSeparator })
+	}
+	return git.SigningKeys{
+		GPGKeys:        list("UGIT_SIGNING_GPG_KEYS"),
+		AllowedSigners: os.Getenv("UGIT_SIGNING_ALLOWED_SIGNERS"),
+		AuthorizedKeys: list("UGIT_SIGNING_AUTHORIZED_KEYS"),
+	}
+}
+
+// hookPusher returns who pushed, as passed to the hook by the protocol
+func hookPusher() git.Pusher {
+	// A missing or invalid access level is NoAccess, so push options are never trusted by mistake
+	access, _ := strconv.Atoi(os.Getenv("UGIT_PUSHER_ACCESS"))
+	return git.Pusher{
+		Name:        os.Getenv("UGIT_PUSHER_NAME"),
+		Fingerprint: os.Getenv("UGIT_PUSHER_FINGERPRINT"),
+		Access:      git.AccessLevel(access),
+	}
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/alecthomas/assert/v2"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"go.jolheiser.com/ugit/internal/git"
+	"go.jolheiser.com/ugit/internal/http"
+)
+
+func TestMain(m *testing.M) {
+	// requiredFS writes hooks that run the test binary, so it has to act as ugitd for them
+	if len(os.Args) > 1 && os.Args[1] == "pre-receive-hook" {
+		preReceive()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// TestProtectedPush pushes over HTTP to a branch that requires signed commits, which goes through
+// the pre-receive hook by default and the receive-pack of the go-git protocol with the gogit tag
+func TestProtectedPush(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is required")
+	}
+	tmp := t.TempDir()
+	repoDir := filepath.Join(tmp, "repos")
+	assert.NoError(t, requiredFS(repoDir))
+	repo, err := git.CreateRepo(repoDir, "test")
+	assert.NoError(t, err)
+	repo.Meta.Protected = []git.ProtectedRef{{Pattern: "main", RequireSigned: true}}
+	assert.NoError(t, repo.SaveMeta())
+
+	trusted, err := openpgp.NewEntity("ugit", "", "ugit@example.com", nil)
+	assert.NoError(t, err)
+	untrusted, err := openpgp.NewEntity("other", "", "other@example.com", nil)
+	assert.NoError(t, err)
+	var keys bytes.Buffer
+	w, err := armor.Encode(&keys, openpgp.PublicKeyType, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, trusted.Serialize(w))
+	assert.NoError(t, w.Close())
+	keysPath := filepath.Join(tmp, "keys.asc")
+	assert.NoError(t, os.WriteFile(keysPath, keys.Bytes(), 0o644))
+
+	srv := httptest.NewServer(http.New(http.Settings{
+		RepoDir:      repoDir,
+		AccessTokens: []http.AccessToken{{User: "ugit", Token: "token"}},
+		Admins:       []string{"ugit"},
+		Signing:      git.SigningKeys{GPGKeys: []string{keysPath}},
+	}).Mux)
+	defer srv.Close()
+	remote := strings.Replace(srv.URL, "http://", "http://ugit:token@", 1) + "/test.git"
+
+	clientDir := filepath.Join(tmp, "client")
+	client, err := gogit.PlainInit(clientDir, false)
+	assert.NoError(t, err)
+	wt, err := client.Worktree()
+	assert.NoError(t, err)
+	commit := func(key *openpgp.Entity) plumbing.Hash {
+		t.Helper()
+		hash, err := wt.Commit("commit", &gogit.CommitOptions{
+			AllowEmptyCommits: true,
+			Author:            &object.Signature{Name: "ugit", Email: "ugit@example.com", When: time.Now()},
+			SignKey:           key,
+		})
+		assert.NoError(t, err)
+		return hash
+	}
+	reset := func(hash plumbing.Hash) {
+		t.Helper()
+		assert.NoError(t, wt.Reset(&gogit.ResetOptions{Commit: hash, Mode: gogit.HardReset}))
+	}
+	push := func() (string, error) {
+		cmd := exec.Command("git", "push", remote, "HEAD:refs/heads/main")
+		cmd.Dir = clientDir
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+	head := func() string {
+		t.Helper()
+		out, err := exec.Command("git", "-C", repo.Path(), "rev-parse", "refs/heads/main").Output()
+		assert.NoError(t, err)
+		return strings.TrimSpace(string(out))
+	}
+
+	signed := commit(trusted)
+	out, err := push()
+	assert.NoError(t, err, out)
+	assert.Equal(t, signed.String(), head())
+
+	unsigned := commit(nil)
+	out, err = push()
+	assert.Error(t, err)
+	assert.Contains(t, out, "commit "+unsigned.String()+" is not signed")
+	assert.Equal(t, signed.String(), head())
+
+	reset(signed)
+	unknown := commit(untrusted)
+	out, err = push()
+	assert.Error(t, err)
+	assert.Contains(t, out, "commit "+unknown.String()+" is not signed by a trusted key (unknown key)")
+	assert.Equal(t, signed.String(), head())
+
+	reset(signed)
+	next := commit(trusted)
+	out, err = push()
+	assert.NoError(t, err, out)
+	assert.Equal(t, next.String(), head())
+}
@@ -0,0 +1,54 @@
+package git
+
+import "slices"
+
+// AccessLevel is the level of access an identity has to a Repo
+type AccessLevel int
+
+const (
+	// NoAccess cannot see or interact with a Repo
+	NoAccess AccessLevel = iota
+	// ReadOnlyAccess can fetch/clone a Repo
+	ReadOnlyAccess
+	// ReadWriteAccess can fetch/clone and push to a Repo
+	ReadWriteAccess
+	// AdminAccess can do anything, including creating new repos
+	AdminAccess
+)
+
+// String implements [fmt.Stringer]
+func (a AccessLevel) String() string {
+	switch a {
+	case ReadOnlyAccess:
+		return "read-only"
+	case ReadWriteAccess:
+		return "read-write"
+	case AdminAccess:
+		return "admin"
+	default:
+		return "no-access"
+	}
+}
+
+// Access returns the AccessLevel granted by the meta to the given identities,
+// which are user names and/or SSH key fingerprints.
+// Public repos are always readable, private repos require the identity to be
+// listed as a reader or writer.
+func (m RepoMeta) Access(identities ...string) AccessLevel {
+	access := NoAccess
+	if !m.Private {
+		access = ReadOnlyAccess
+	}
+	for _, id := range identities {
+		if id == "" {
+			continue
+		}
+		if slices.Contains(m.Writers, id) {
+			return ReadWriteAccess
+		}
+		if slices.Contains(m.Readers, id) {
+			access = ReadOnlyAccess
+		}
+	}
+	return access
+}
@@ -0,0 +1,164 @@
+package git
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ArchiveFormat is a supported archive format
+type ArchiveFormat string
+
+const (
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	ArchiveZip   ArchiveFormat = "zip"
+)
+
+// ArchiveFormats are all supported archive formats
+var ArchiveFormats = []ArchiveFormat{ArchiveTarGz, ArchiveZip}
+
+// ErrUnknownArchiveFormat is returned for an unsupported ArchiveFormat
+var ErrUnknownArchiveFormat = errors.New("unknown archive format")
+
+// ParseArchive splits a path such as "v1.0.0.tar.gz" into its ref and ArchiveFormat
+func ParseArchive(p string) (string, ArchiveFormat, error) {
+	for _, format := range ArchiveFormats {
+		if ref, ok := strings.CutSuffix(p, "."+string(format)); ok && ref != "" {
+			return ref, format, nil
+		}
+	}
+	return "", "", ErrUnknownArchiveFormat
+}
+
+// ArchivePrefix returns the directory files in an archive are stored under, e.g. "ugit-v1.0.0"
+func (r Repo) ArchivePrefix(ref string) string {
+	return fmt.Sprintf("%s-%s", path.Base(r.Name()), strings.ReplaceAll(ref, "/", "-"))
+}
+
+// Archive writes an archive of the tree at ref, with every file under the ArchivePrefix directory
+func (r Repo) Archive(w io.Writer, ref string, format ArchiveFormat) error {
+	commit, err := r.GetCommitFromRef(ref)
+	if err != nil {
+		return err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+
+	prefix := r.ArchivePrefix(ref) + "/"
+	when := commit.Committer.When
+	switch format {
+	case ArchiveTarGz:
+		return archiveTarGz(w, tree, prefix, when)
+	case ArchiveZip:
+		return archiveZip(w, tree, prefix, when)
+	default:
+		return ErrUnknownArchiveFormat
+	}
+}
+
+func archiveTarGz(w io.Writer, tree *object.Tree, prefix string, when time.Time) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     prefix,
+		Mode:     0o755,
+		ModTime:  when,
+	}); err != nil {
+		return err
+	}
+
+	if err := tree.Files().ForEach(func(f *object.File) error {
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     prefix + f.Name,
+			Mode:     0o644,
+			Size:     f.Size,
+			ModTime:  when,
+		}
+		switch f.Mode {
+		case filemode.Executable:
+			hdr.Mode = 0o755
+		case filemode.Symlink:
+			target, err := f.Contents()
+			if err != nil {
+				return err
+			}
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = target
+			hdr.Mode = 0o777
+			hdr.Size = 0
+			return tw.WriteHeader(hdr)
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		return copyBlob(tw, f)
+	}); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func archiveZip(w io.Writer, tree *object.Tree, prefix string, when time.Time) error {
+	zw := zip.NewWriter(w)
+
+	if _, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     prefix,
+		Modified: when,
+	}); err != nil {
+		return err
+	}
+
+	if err := tree.Files().ForEach(func(f *object.File) error {
+		hdr := &zip.FileHeader{
+			Name:     prefix + f.Name,
+			Method:   zip.Deflate,
+			Modified: when,
+		}
+		switch f.Mode {
+		case filemode.Executable:
+			hdr.SetMode(0o755)
+		case filemode.Symlink:
+			hdr.SetMode(os.ModeSymlink | 0o777)
+		default:
+			hdr.SetMode(0o644)
+		}
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		return copyBlob(fw, f)
+	}); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func copyBlob(w io.Writer, f *object.File) error {
+	r, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(w, r)
+	return err
+}
@@ -0,0 +1,60 @@
+package git
+
+import (
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// BlameHunk is a group of consecutive lines last changed by the same commit
+type BlameHunk struct {
+	Commit    Commit
+	StartLine int
+	Lines     []string
+}
+
+// Blame returns the BlameHunks of a file at a given ref
+func (r Repo) Blame(ref, path string) ([]BlameHunk, error) {
+	repo, err := r.Git()
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := r.GetCommitFromRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := git.Blame(commit, path)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make(map[plumbing.Hash]string)
+	var hunks []BlameHunk
+	for idx, line := range result.Lines {
+		if len(hunks) > 0 && hunks[len(hunks)-1].Commit.SHA == line.Hash.String() {
+			hunks[len(hunks)-1].Lines = append(hunks[len(hunks)-1].Lines, line.Text)
+			continue
+		}
+		message, ok := summaries[line.Hash]
+		if !ok {
+			if c, err := repo.CommitObject(line.Hash); err == nil {
+				message = c.Message
+			}
+			summaries[line.Hash] = message
+		}
+		hunks = append(hunks, BlameHunk{
+			Commit: Commit{
+				SHA:     line.Hash.String(),
+				Message: message,
+				Author:  line.AuthorName,
+				Email:   line.Author,
+				When:    line.Date,
+			},
+			StartLine: idx + 1,
+			Lines:     []string{line.Text},
+		})
+	}
+
+	return hunks, nil
+}
@@ -0,0 +1,159 @@
+package git
+
+import (
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Catalog is an in-memory listing of the repos in a directory and its namespaces, caching their meta and last commit
+// Meta is reloaded when a repo's ugit.json changes, while the last commit is reloaded when HEAD changes or after Invalidate
+type Catalog struct {
+	dir     string
+	mu      sync.Mutex
+	entries map[string]*catalogEntry
+}
+
+type catalogEntry struct {
+	repo        *Repo
+	metaModTime time.Time
+	metaSize    int64
+	headModTime time.Time
+	lastCommit  *Commit
+	stale       bool
+}
+
+// CatalogEntry is a repo in a Catalog
+type CatalogEntry struct {
+	Repo *Repo
+	// LastCommit is the commit HEAD points to, or nil for an empty repo
+	LastCommit *Commit
+}
+
+// NewCatalog returns a Catalog for the repos in dir
+func NewCatalog(dir string) *Catalog {
+	return &Catalog{
+		dir:     dir,
+		entries: make(map[string]*catalogEntry),
+	}
+}
+
+// Repos returns every repo in the catalog, most recently updated first
+// Each call returns copies, so callers are free to modify them
+func (c *Catalog) Repos() ([]CatalogEntry, error) {
+	var names []string
+	if err := WalkRepos(c.dir, func(name string) error {
+		names = append(names, name)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Namespace meta isn't cached, but is only read once per call
+	private := make(map[string]bool)
+	seen := make(map[string]struct{}, len(names))
+	repos := make([]CatalogEntry, 0, len(names))
+	for _, name := range names {
+		seen[name] = struct{}{}
+
+		entry, err := c.load(name)
+		if err != nil {
+			return nil, err
+		}
+		repo := *entry.repo
+		repo.Meta.Tags = maps.Clone(repo.Meta.Tags)
+		ns := repo.Namespace()
+		if _, ok := private[ns]; !ok {
+			private[ns] = namespacePrivate(c.dir, ns)
+		}
+		repo.inherited = private[ns]
+		repos = append(repos, CatalogEntry{
+			Repo:       &repo,
+			LastCommit: entry.lastCommit,
+		})
+	}
+	for name := range c.entries {
+		if _, ok := seen[name]; !ok {
+			delete(c.entries, name)
+		}
+	}
+
+	slices.SortStableFunc(repos, func(a, b CatalogEntry) int {
+		return b.updated().Compare(a.updated())
+	})
+	return repos, nil
+}
+
+func (e CatalogEntry) updated() time.Time {
+	if e.LastCommit == nil {
+		return time.Time{}
+	}
+	return e.LastCommit.When
+}
+
+// load returns the cached entry for a repo, refreshing anything that is out of date
+func (c *Catalog) load(name string) (*catalogEntry, error) {
+	entry := c.entries[name]
+
+	info, err := os.Stat(Repo{path: repoPath(c.dir, name)}.metaPath())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if entry == nil || info == nil || !info.ModTime().Equal(entry.metaModTime) || info.Size() != entry.metaSize {
+		repo, err := NewRepo(c.dir, name)
+		if err != nil {
+			// The meta may be mid-write, keep serving the previous copy until it can be read
+			if entry != nil {
+				return entry, nil
+			}
+			return nil, err
+		}
+		if entry == nil {
+			entry = &catalogEntry{stale: true}
+			c.entries[name] = entry
+		}
+		entry.repo = repo
+		// NewRepo creates the meta if it was missing, so stat again
+		if info, err := os.Stat(repo.metaPath()); err == nil {
+			entry.metaModTime, entry.metaSize = info.ModTime(), info.Size()
+		}
+	}
+
+	// HEAD changes when the default branch does
+	if info, err := os.Stat(filepath.Join(entry.repo.path, "HEAD")); err == nil && !info.ModTime().Equal(entry.headModTime) {
+		entry.headModTime = info.ModTime()
+		entry.stale = true
+	}
+
+	if entry.stale {
+		entry.lastCommit = nil
+		if commit, err := entry.repo.LastCommit(); err == nil {
+			entry.lastCommit = &commit
+		}
+		entry.stale = false
+	}
+	return entry, nil
+}
+
+// Invalidate marks the last commit of a repo as out of date, e.g. after a push
+func (c *Catalog) Invalidate(name string) {
+	name, err := cleanName(name)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[name]; ok {
+		entry.stale = true
+	}
+}
@@ -0,0 +1,192 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ErrInvalidCompare is returned when a compare path is not in the form "base...head"
+var ErrInvalidCompare = errors.New("invalid compare, expected base...head")
+
+// CompareSuffixes are the suffixes that request a raw patch series for a comparison
+var CompareSuffixes = []string{".patch", ".diff"}
+
+// ParseCompare splits a path such as "main...feature.patch" into its base and head refs,
+// and whether a raw patch series was requested
+func ParseCompare(p string) (string, string, bool, error) {
+	var raw bool
+	for _, suffix := range CompareSuffixes {
+		if trimmed, ok := strings.CutSuffix(p, suffix); ok {
+			p, raw = trimmed, true
+			break
+		}
+	}
+	base, head, ok := strings.Cut(p, "...")
+	if !ok || base == "" || head == "" {
+		return "", "", false, ErrInvalidCompare
+	}
+	return base, head, raw, nil
+}
+
+// Comparison is the difference between two refs
+type Comparison struct {
+	Base      string
+	Head      string
+	MergeBase string
+
+	// Commits reachable from Head but not Base, in descending order
+	Commits []Commit
+	Stats   CommitStats
+	Patch   string
+	Files   []CommitFile
+}
+
+// Compare returns the commits reachable from head but not base, along with the diff
+// between their merge base and head
+func (r Repo) Compare(base, head string) (Comparison, error) {
+	baseCommit, err := r.GetCommitFromRef(base)
+	if err != nil {
+		return Comparison{}, err
+	}
+	headCommit, err := r.GetCommitFromRef(head)
+	if err != nil {
+		return Comparison{}, err
+	}
+
+	objs, err := compareCommits(baseCommit, headCommit)
+	if err != nil {
+		return Comparison{}, err
+	}
+
+	comparison := Comparison{
+		Base: base,
+		Head: head,
+	}
+	for _, obj := range objs {
+		comparison.Commits = append(comparison.Commits, newCommit(obj))
+	}
+
+	var baseTree *object.Tree
+	bases, err := baseCommit.MergeBase(headCommit)
+	if err != nil {
+		return Comparison{}, err
+	}
+	if len(bases) > 0 {
+		comparison.MergeBase = bases[0].Hash.String()
+		baseTree, err = bases[0].Tree()
+		if err != nil {
+			return Comparison{}, err
+		}
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return Comparison{}, err
+	}
+
+	comparison.Stats, comparison.Patch, comparison.Files, err = diff(baseTree, headTree, comparison.MergeBase, headCommit.Hash.String())
+	if err != nil {
+		return Comparison{}, err
+	}
+
+	return comparison, nil
+}
+
+// compareCommits returns the commits reachable from head but not base, in descending order
+func compareCommits(base, head *object.Commit) ([]*object.Commit, error) {
+	seen := make(map[plumbing.Hash]bool)
+	if err := object.NewCommitPreorderIter(base, nil, nil).ForEach(func(c *object.Commit) error {
+		seen[c.Hash] = true
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	var commits []*object.Commit
+	if err := object.NewCommitPreorderIter(head, seen, nil).ForEach(func(c *object.Commit) error {
+		commits = append(commits, c)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return commits, nil
+}
+
+// PatchSeries writes the commits reachable from head but not base as an mbox patch series,
+// oldest first, suitable for git am
+// Merge commits are skipped, as with git format-patch
+func (r Repo) PatchSeries(w io.Writer, base, head string) error {
+	baseCommit, err := r.GetCommitFromRef(base)
+	if err != nil {
+		return err
+	}
+	headCommit, err := r.GetCommitFromRef(head)
+	if err != nil {
+		return err
+	}
+
+	objs, err := compareCommits(baseCommit, headCommit)
+	if err != nil {
+		return err
+	}
+
+	var series []*object.Commit
+	for idx := len(objs) - 1; idx >= 0; idx-- {
+		if objs[idx].NumParents() > 1 {
+			continue
+		}
+		series = append(series, objs[idx])
+	}
+
+	for idx, obj := range series {
+		var parentTree *object.Tree
+		if obj.NumParents() > 0 {
+			parent, err := obj.Parent(0)
+			if err != nil {
+				return err
+			}
+			parentTree, err = parent.Tree()
+			if err != nil {
+				return err
+			}
+		}
+		tree, err := obj.Tree()
+		if err != nil {
+			return err
+		}
+		_, patch, _, err := diff(parentTree, tree, "", "")
+		if err != nil {
+			return err
+		}
+
+		prefix := "[PATCH]"
+		if len(series) > 1 {
+			prefix = fmt.Sprintf("[PATCH %d/%d]", idx+1, len(series))
+		}
+		subject, body, _ := strings.Cut(strings.TrimSpace(obj.Message), "\n")
+		body = strings.TrimSpace(body)
+		if body != "" {
+			body += "\n"
+		}
+
+		if _, err := fmt.Fprintf(w, "From %s Mon Sep 17 00:00:00 2001\nFrom: %s <%s>\nDate: %s\nSubject: %s %s\n\n%s---\n\n%s-- \nugit\n\n",
+			obj.Hash,
+			obj.Author.Name,
+			obj.Author.Email,
+			obj.Author.When.Format(time.RFC1123Z),
+			prefix,
+			subject,
+			body,
+			patch,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
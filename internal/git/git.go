@@ -77,6 +77,8 @@ type FileInfo struct {
 	IsDir bool
 	Mode  string
 	Size  string
+	// LFS is whether the file is an LFS pointer, in which case Size is the size of the object
+	LFS bool
 }
 
 // Name returns the last part of the FileInfo.Path
@@ -84,6 +86,19 @@ func (f FileInfo) Name() string {
 	return filepath.Base(f.Path)
 }
 
+// lfsPointer returns the LFS pointer in a file of a tree, if it is one
+func lfsPointer(t *object.Tree, name string) (LFSPointer, bool) {
+	f, err := t.File(name)
+	if err != nil {
+		return LFSPointer{}, false
+	}
+	content, err := f.Contents()
+	if err != nil {
+		return LFSPointer{}, false
+	}
+	return ParseLFSPointer([]byte(content))
+}
+
 // Dir returns the given dirpath in the given ref as a slice of FileInfo
 // Sorted alphabetically, dirs first
 func (r Repo) Dir(ref, path string) ([]FileInfo, error) {
@@ -108,11 +123,18 @@ func (r Repo) Dir(ref, path string) ([]FileInfo, error) {
 		if err != nil {
 			return nil, err
 		}
+		var lfs bool
+		if !fm.IsDir() && size < lfsPointerMaxSize {
+			if ptr, ok := lfsPointer(t, entry.Name); ok {
+				size, lfs = ptr.Size, true
+			}
+		}
 		fis = append(fis, FileInfo{
 			Path:  filepath.Join(path, entry.Name),
 			IsDir: fm.IsDir(),
 			Mode:  fm.String(),
 			Size:  humanize.Bytes(uint64(size)),
+			LFS:   lfs,
 		})
 	}
 	sort.Slice(fis, func(i, j int) bool {
@@ -1,13 +1,25 @@
 package git
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
 	"regexp"
 	"strings"
+	"sync"
 
+	"github.com/alecthomas/chroma/v2/lexers"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 )
 
+// ErrInvalidSearch is returned when a search has an invalid qualifier or no pattern
+var ErrInvalidSearch = errors.New("invalid search")
+
 // GrepResult is the result of a search
 type GrepResult struct {
 	File      string
@@ -16,42 +28,342 @@ type GrepResult struct {
 	Content   string
 }
 
-// Grep performs a naive "code search" via git grep
-func (r Repo) Grep(search string) ([]GrepResult, error) {
+// Search is a search pattern along with the qualifiers narrowing it
+type Search struct {
+	// Pattern is the regular expression to search for, see GrepPattern
+	Pattern string
+	// Ref is the ref to search, or HEAD if empty
+	Ref string
+	// Paths are globs that files must match one of, "*" matches within a directory and "**" across directories
+	Paths []string
+	// Langs are languages that files must be one of, e.g. "go"
+	Langs []string
+	// IgnoreCase matches the pattern case-insensitively
+	IgnoreCase bool
+}
+
+var searchQualifierRe = regexp.MustCompile(`(?:^|\s)(ref|path|lang|case):(\S*)`)
+
+// ParseSearch parses the qualifiers out of a search such as "ref:v1.2 path:internal/** lang:go case:no foo"
+// Anything that isn't a qualifier is the pattern
+func ParseSearch(search string) (Search, error) {
+	var s Search
+	var pattern strings.Builder
+	var last int
+	for _, match := range searchQualifierRe.FindAllStringSubmatchIndex(search, -1) {
+		pattern.WriteString(search[last:match[0]])
+		last = match[1]
+
+		qualifier, value := search[match[2]:match[3]], search[match[4]:match[5]]
+		if value == "" {
+			return Search{}, fmt.Errorf("%w: %s: requires a value", ErrInvalidSearch, qualifier)
+		}
+		switch qualifier {
+		case "ref":
+			s.Ref = value
+		case "path":
+			s.Paths = append(s.Paths, value)
+		case "lang":
+			s.Langs = append(s.Langs, strings.ToLower(value))
+		case "case":
+			switch value {
+			case "yes":
+				s.IgnoreCase = false
+			case "no":
+				s.IgnoreCase = true
+			default:
+				return Search{}, fmt.Errorf("%w: case: must be yes or no", ErrInvalidSearch)
+			}
+		}
+	}
+	pattern.WriteString(search[last:])
+
+	s.Pattern = strings.TrimSpace(pattern.String())
+	if s.Pattern == "" {
+		return Search{}, fmt.Errorf("%w: missing pattern", ErrInvalidSearch)
+	}
+	return s, nil
+}
+
+// regexp compiles the search pattern
+func (s Search) regexp() (*regexp.Regexp, error) {
+	re, err := GrepPattern(s.Pattern)
+	if err != nil || !s.IgnoreCase {
+		return re, err
+	}
+	return regexp.Compile("(?i)" + re.String())
+}
+
+// pathSpecs compiles the path globs of the search
+func (s Search) pathSpecs() ([]*regexp.Regexp, error) {
+	specs := make([]*regexp.Regexp, 0, len(s.Paths))
+	for _, glob := range s.Paths {
+		spec, err := globPattern(glob)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// langFilter returns whether a file is one of the search languages, or nil if the search isn't limited by language
+func (s Search) langFilter() func(string) bool {
+	if len(s.Langs) == 0 {
+		return nil
+	}
+	// Lexer matching is comparatively slow, so cache it by extension or name
+	cache := make(map[string]bool)
+	return func(name string) bool {
+		key := path.Ext(name)
+		if key == "" {
+			key = path.Base(name)
+		}
+		if ok, cached := cache[key]; cached {
+			return ok
+		}
+
+		var ok bool
+		if lexer := lexers.Match(path.Base(name)); lexer != nil {
+			config := lexer.Config()
+			for _, lang := range s.Langs {
+				if strings.EqualFold(config.Name, lang) || containsFold(config.Aliases, lang) {
+					ok = true
+					break
+				}
+			}
+		}
+		cache[key] = ok
+		return ok
+	}
+}
+
+func containsFold(s []string, v string) bool {
+	for _, e := range s {
+		if strings.EqualFold(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// globPattern compiles a path glob into a regular expression
+// A glob without wildcards matches the path itself or anything beneath it
+func globPattern(glob string) (*regexp.Regexp, error) {
+	glob = strings.Trim(glob, "/")
+	if !strings.ContainsAny(glob, "*?") {
+		return regexp.Compile("^" + regexp.QuoteMeta(glob) + "(?:/.*)?$")
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			pattern.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			pattern.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			pattern.WriteString("[^/]*")
+		case glob[i] == '?':
+			pattern.WriteString("[^/]")
+		default:
+			pattern.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	pattern.WriteString("$")
+	return regexp.Compile(pattern.String())
+}
+
+// GrepOptions are options for a search
+type GrepOptions struct {
+	// Context is the number of lines shown before and after each match
+	Context int
+	// Limit is the maximum number of results to return, or 0 for no limit
+	Limit int
+	// Offset is the number of results to skip, for pagination
+	Offset int
+}
+
+// GrepPattern compiles a search into a regular expression
+// A search prefixed with "=" matches the rest of the search literally
+func GrepPattern(search string) (*regexp.Regexp, error) {
 	if after, ok := strings.CutPrefix(search, "="); ok {
 		search = regexp.QuoteMeta(after)
 	}
-	re, err := regexp.Compile(search)
+	return regexp.Compile(search)
+}
+
+// Grep performs a naive "code search" via git grep
+// The search may contain qualifiers, see ParseSearch
+// Along with the results, Grep returns whether there are more results past opts.Limit
+func (r Repo) Grep(search string, opts GrepOptions) ([]GrepResult, bool, error) {
+	s, err := ParseSearch(search)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+	return r.grep(s, opts)
+}
+
+func (r Repo) grep(s Search, opts GrepOptions) ([]GrepResult, bool, error) {
+	re, err := s.regexp()
+	if err != nil {
+		return nil, false, err
+	}
+	pathSpecs, err := s.pathSpecs()
+	if err != nil {
+		return nil, false, err
 	}
 
 	repo, err := r.Git()
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	// Loosely modifed from
 	// https://github.com/go-git/go-git/blob/fb04aa392c8d4c259cb5b21c1cb4c6f8076e600b/options.go#L736-L740
 	// https://github.com/go-git/go-git/blob/fb04aa392c8d4c259cb5b21c1cb4c6f8076e600b/worktree.go#L753-L760
-	ref, err := repo.Head()
-	if err != nil {
-		return nil, err
+	var hash plumbing.Hash
+	if s.Ref == "" {
+		ref, err := repo.Head()
+		if err != nil {
+			return nil, false, err
+		}
+		hash = ref.Hash()
+	} else {
+		h, err := repo.ResolveRevision(plumbing.Revision(s.Ref))
+		if err != nil {
+			return nil, false, err
+		}
+		hash = *h
 	}
 
-	commit, err := repo.CommitObject(ref.Hash())
+	commit, err := repo.CommitObject(hash)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	tree, err := commit.Tree()
 	if err != nil {
+		return nil, false, err
+	}
+
+	// Narrow the files to scan using the search index when it's up to date
+	keep, _ := r.searchCandidates(hash.String(), re)
+	if lang := s.langFilter(); lang != nil {
+		if indexed := keep; indexed != nil {
+			keep = func(name string) bool {
+				return indexed(name) && lang(name)
+			}
+		} else {
+			keep = lang
+		}
+	}
+
+	limit := 0
+	if opts.Limit > 0 {
+		// Find one extra result to know whether there are more
+		limit = opts.Offset + opts.Limit + 1
+	}
+	results, err := findMatchInFiles(tree.Files(), hash.String(), &git.GrepOptions{
+		Patterns:  []*regexp.Regexp{re},
+		PathSpecs: pathSpecs,
+	}, keep, max(opts.Context, 0), limit)
+	if err != nil {
+		return nil, false, err
+	}
+
+	results = results[min(opts.Offset, len(results)):]
+	var more bool
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results, more = results[:opts.Limit], true
+	}
+	return results, more, nil
+}
+
+// RepoGrepResult is the result of searching a single Repo
+type RepoGrepResult struct {
+	Repo    *Repo
+	Ref     string
+	Results []GrepResult
+	// More is whether the repo has more results than were returned
+	More bool
+}
+
+// GrepRepos searches every repo, running at most workers searches at once
+// Repos are searched at their default branch unless the search has a ref qualifier
+// Only repos with results are returned, in the same order they were given
+// Repos that can't be searched, such as empty repos or repos without the ref, are skipped
+func GrepRepos(ctx context.Context, repos []*Repo, search string, opts GrepOptions, workers int) ([]RepoGrepResult, error) {
+	s, err := ParseSearch(search)
+	if err != nil {
+		return nil, err
+	}
+	// Catch invalid patterns up front rather than once per repo
+	if _, err := s.regexp(); err != nil {
+		return nil, err
+	}
+	if _, err := s.pathSpecs(); err != nil {
 		return nil, err
 	}
 
-	return findMatchInFiles(tree.Files(), ref.Hash().String(), &git.GrepOptions{
-		Patterns: []*regexp.Regexp{re},
-	})
+	results := make([]RepoGrepResult, len(repos))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for range max(1, min(workers, len(repos))) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range queue {
+				repo := repos[idx]
+				rs := s
+				if rs.Ref == "" {
+					ref, err := repo.DefaultBranch()
+					if err != nil {
+						continue
+					}
+					rs.Ref = ref
+				}
+				found, more, err := repo.grep(rs, opts)
+				if err != nil {
+					if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+						slog.Error("could not search repo", "repo", repo.Name(), "error", err)
+					}
+					continue
+				}
+				results[idx] = RepoGrepResult{
+					Repo:    repo,
+					Ref:     rs.Ref,
+					Results: found,
+					More:    more,
+				}
+			}
+		}()
+	}
+
+queue:
+	for idx := range repos {
+		select {
+		case queue <- idx:
+		case <-ctx.Done():
+			break queue
+		}
+	}
+	close(queue)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	matched := make([]RepoGrepResult, 0, len(results))
+	for _, result := range results {
+		if len(result.Results) > 0 {
+			matched = append(matched, result)
+		}
+	}
+	return matched, nil
 }
 
 // Lines below are copied and modifed from https://github.com/go-git/go-git/blob/fb04aa392c8d4c259cb5b21c1cb4c6f8076e600b/worktree.go#L961-L1045
@@ -59,10 +371,17 @@ func (r Repo) Grep(search string) ([]GrepResult, error) {
 // findMatchInFiles takes a FileIter, worktree name and GrepOptions, and
 // returns a slice of GrepResult containing the result of regex pattern matching
 // in content of all the files.
-func findMatchInFiles(fileiter *object.FileIter, treeName string, opts *git.GrepOptions) ([]GrepResult, error) {
+// If keep is non-nil, only files it returns true for are searched.
+// Each result includes contextLines lines before and after the match, and
+// searching stops once limit results are found, unless limit is 0.
+func findMatchInFiles(fileiter *object.FileIter, treeName string, opts *git.GrepOptions, keep func(string) bool, contextLines, limit int) ([]GrepResult, error) {
 	var results []GrepResult
 
 	err := fileiter.ForEach(func(file *object.File) error {
+		if keep != nil && !keep(file.Name) {
+			return nil
+		}
+
 		var fileInPathSpec bool
 
 		// When no pathspecs are provided, search all the files.
@@ -84,12 +403,16 @@ func findMatchInFiles(fileiter *object.FileIter, treeName string, opts *git.Grep
 			return nil
 		}
 
-		grepResults, err := findMatchInFile(file, treeName, opts)
+		grepResults, err := findMatchInFile(file, treeName, opts, contextLines)
 		if err != nil {
 			return err
 		}
 		results = append(results, grepResults...)
 
+		if limit > 0 && len(results) >= limit {
+			results = results[:limit]
+			return storer.ErrStop
+		}
 		return nil
 	})
 
@@ -99,7 +422,7 @@ func findMatchInFiles(fileiter *object.FileIter, treeName string, opts *git.Grep
 // findMatchInFile takes a single File, worktree name and GrepOptions,
 // and returns a slice of GrepResult containing the result of regex pattern
 // matching in the given file.
-func findMatchInFile(file *object.File, treeName string, opts *git.GrepOptions) ([]GrepResult, error) {
+func findMatchInFile(file *object.File, treeName string, opts *git.GrepOptions, contextLines int) ([]GrepResult, error) {
 	var grepResults []GrepResult
 
 	content, err := file.Contents()
@@ -130,20 +453,13 @@ func findMatchInFile(file *object.File, treeName string, opts *git.GrepOptions)
 		}
 
 		if addToResult {
-			startLine := lineNum + 1
-			ctx := []string{cnt}
-			if lineNum != 0 {
-				startLine -= 1
-				ctx = append([]string{contentByLine[lineNum-1]}, ctx...)
-			}
-			if lineNum != len(contentByLine)-1 {
-				ctx = append(ctx, contentByLine[lineNum+1])
-			}
+			start := max(lineNum-contextLines, 0)
+			end := min(lineNum+contextLines+1, len(contentByLine))
 			grepResults = append(grepResults, GrepResult{
 				File:      file.Name,
-				StartLine: startLine,
+				StartLine: start + 1,
 				Line:      lineNum + 1,
-				Content:   strings.Join(ctx, "\n"),
+				Content:   strings.Join(contentByLine[start:end], "\n"),
 			})
 		}
 	}
@@ -0,0 +1,225 @@
+package git
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrLFSObjectNotFound is returned for an LFS object that hasn't been uploaded
+	ErrLFSObjectNotFound = errors.New("lfs object not found")
+	// ErrLFSObjectMismatch is returned when uploaded content doesn't match its oid or size
+	ErrLFSObjectMismatch = errors.New("lfs object does not match its oid or size")
+)
+
+// lfsPointerMaxSize is the largest a pointer file can be, larger files are never pointers
+const lfsPointerMaxSize = 1024
+
+var lfsOIDRe = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// ValidLFSOID returns whether an oid is a SHA-256 hex digest, which is all ugit stores
+func ValidLFSOID(oid string) bool {
+	return lfsOIDRe.MatchString(oid)
+}
+
+// LFSPointer is the file committed in place of an object stored with Git LFS
+type LFSPointer struct {
+	OID  string
+	Size int64
+}
+
+// ParseLFSPointer parses an LFS pointer file, returning false if the content isn't one
+func ParseLFSPointer(content []byte) (LFSPointer, bool) {
+	var ptr LFSPointer
+	if len(content) >= lfsPointerMaxSize || !bytes.HasPrefix(content, []byte("version https://git-lfs.github.com/spec/")) {
+		return ptr, false
+	}
+	var hasSize bool
+	for line := range strings.SplitSeq(strings.TrimSpace(string(content)), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "oid":
+			oid, ok := strings.CutPrefix(value, "sha256:")
+			if !ok || !ValidLFSOID(oid) {
+				return ptr, false
+			}
+			ptr.OID = oid
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return ptr, false
+			}
+			ptr.Size, hasSize = size, true
+		}
+	}
+	return ptr, ptr.OID != "" && hasSize
+}
+
+// lfsObjectPath returns where an object is stored, using the same layout as a local .git/lfs/objects
+func (r Repo) lfsObjectPath(oid string) string {
+	return filepath.Join(r.path, "lfs", "objects", oid[:2], oid[2:4], oid)
+}
+
+// LFSObjectSize returns the size of a stored LFS object
+func (r Repo) LFSObjectSize(oid string) (int64, error) {
+	if !ValidLFSOID(oid) {
+		return 0, ErrLFSObjectNotFound
+	}
+	fi, err := os.Stat(r.lfsObjectPath(oid))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, ErrLFSObjectNotFound
+		}
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// OpenLFSObject opens a stored LFS object
+func (r Repo) OpenLFSObject(oid string) (*os.File, error) {
+	if !ValidLFSOID(oid) {
+		return nil, ErrLFSObjectNotFound
+	}
+	fi, err := os.Open(r.lfsObjectPath(oid))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrLFSObjectNotFound
+		}
+		return nil, err
+	}
+	return fi, nil
+}
+
+// LFSObjectContent returns the content of a stored LFS object
+func (r Repo) LFSObjectContent(oid string) (string, error) {
+	fi, err := r.OpenLFSObject(oid)
+	if err != nil {
+		return "", err
+	}
+	defer fi.Close()
+	content, err := io.ReadAll(fi)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// StoreLFSObject stores an LFS object, only keeping it if the content matches the oid and size
+func (r Repo) StoreLFSObject(oid string, size int64, content io.Reader) error {
+	if !ValidLFSOID(oid) {
+		return ErrLFSObjectMismatch
+	}
+	tmpDir := filepath.Join(r.path, "lfs", "tmp")
+	if err := os.MkdirAll(tmpDir, os.ModeDir|os.FileMode(0o700)); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(tmpDir, oid)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	// Read one byte past the size so oversized content is caught without reading all of it
+	n, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(content, size+1))
+	if err != nil {
+		return err
+	}
+	if n != size || hex.EncodeToString(hash.Sum(nil)) != oid {
+		return ErrLFSObjectMismatch
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	path := r.lfsObjectPath(oid)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModeDir|os.FileMode(0o700)); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LFSAuth hands out short-lived tokens over SSH via git-lfs-authenticate, which the HTTP server accepts for LFS transfers
+type LFSAuth struct {
+	// URL is the HTTP clone URL base that LFS clients are sent to
+	URL    string
+	secret []byte
+}
+
+// LFSTokenExpiry is how long a token from LFSAuth is valid
+var LFSTokenExpiry = time.Hour
+
+// NewLFSAuth returns an LFSAuth for the HTTP server at url with a random secret,
+// so tokens don't survive a restart, which clients handle by asking for a new one
+func NewLFSAuth(url string) (*LFSAuth, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return &LFSAuth{
+		URL:    strings.TrimSuffix(url, "/"),
+		secret: secret,
+	}, nil
+}
+
+// Href returns the LFS endpoint of a repo
+func (a LFSAuth) Href(repo string) string {
+	return fmt.Sprintf("%s/%s.git/info/lfs", a.URL, repo)
+}
+
+// Token returns a token granting access to the LFS objects of a repo, along with when it expires
+func (a LFSAuth) Token(repo string, access AccessLevel) (string, time.Time) {
+	expiry := time.Now().Add(LFSTokenExpiry)
+	payload := fmt.Sprintf("%s|%d|%d", repo, access, expiry.Unix())
+	token := base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(a.sign(payload))
+	return token, expiry
+}
+
+// Verify returns the AccessLevel a token grants to a repo, which is NoAccess for an invalid or expired token
+func (a LFSAuth) Verify(token, repo string) AccessLevel {
+	encodedPayload, encodedSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return NoAccess
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return NoAccess
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil || !hmac.Equal(sig, a.sign(string(payload))) {
+		return NoAccess
+	}
+	parts := strings.Split(string(payload), "|")
+	if len(parts) != 3 || parts[0] != repo {
+		return NoAccess
+	}
+	access, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return NoAccess
+	}
+	expiry, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || time.Now().After(time.Unix(expiry, 0)) {
+		return NoAccess
+	}
+	return AccessLevel(access)
+}
+
+func (a LFSAuth) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
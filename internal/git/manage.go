@@ -0,0 +1,153 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+var (
+	// ErrRepoExists is returned when creating or renaming to a repo that already exists
+	ErrRepoExists = errors.New("repo already exists")
+	// ErrInvalidRepoName is returned for a name that can't be used for a repo
+	ErrInvalidRepoName = errors.New("invalid repo name")
+)
+
+var repoNameRe = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]*$`)
+
+// reservedNamespaces can't be used as top-level namespaces because they clash with routes or the hooks dir
+var reservedNamespaces = []string{"_", "api", "hooks"}
+
+// reservedNames can't be used as top-level repo names because their URLs are taken by routes
+var reservedNames = []string{"_"}
+
+// ValidRepoName returns whether a name, with or without the .git suffix, can be used for a new repo
+// The name may include namespaces, e.g. "group/repo", each of which must also be a valid name
+func ValidRepoName(name string) bool {
+	name = strings.TrimSuffix(name, ".git")
+	parts := strings.Split(name, "/")
+	if len(parts) > 1 && slices.Contains(reservedNamespaces, parts[0]) {
+		return false
+	}
+	if len(parts) == 1 && slices.Contains(reservedNames, parts[0]) {
+		return false
+	}
+	for idx, part := range parts {
+		if !repoNameRe.MatchString(part) || strings.HasSuffix(part, ".") {
+			return false
+		}
+		if idx < len(parts)-1 && strings.HasSuffix(part, ".git") {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateNewRepo checks that a repo can be created with the given name,
+// which means it must be valid, not exist yet and not clash with a namespace or with a repo named like one of its namespaces
+func ValidateNewRepo(dir, name string) error {
+	if !ValidRepoName(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidRepoName, name)
+	}
+	name, err := cleanName(name)
+	if err != nil {
+		return err
+	}
+	exists, err := PathExists(repoPath(dir, name))
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrRepoExists
+	}
+	for ns := range parentNamespaces(name) {
+		exists, err := PathExists(repoPath(dir, ns))
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("%w: %q is a repo", ErrInvalidRepoName, ns)
+		}
+	}
+	exists, err = PathExists(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: %q is a namespace", ErrInvalidRepoName, name)
+	}
+	return nil
+}
+
+// CreateRepo creates a new bare repo, failing if it already exists
+func CreateRepo(dir, name string) (*Repo, error) {
+	if err := ValidateNewRepo(dir, name); err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(name, ".git") {
+		name += ".git"
+	}
+	if err := EnsureRepo(dir, name); err != nil {
+		return nil, err
+	}
+	return NewRepo(dir, name)
+}
+
+// Rename moves the Repo to a new name, which may be in another namespace
+func (r *Repo) Rename(name string) error {
+	if err := ValidateNewRepo(r.dir, name); err != nil {
+		return err
+	}
+	name, err := cleanName(name)
+	if err != nil {
+		return err
+	}
+	path := repoPath(r.dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModeDir|os.FileMode(0o700)); err != nil {
+		return err
+	}
+	if err := os.Rename(r.path, path); err != nil {
+		return err
+	}
+	r.pruneNamespaces()
+	r.name, r.path = name, path
+	r.inherited = namespacePrivate(r.dir, r.Namespace())
+	return nil
+}
+
+// Delete removes the Repo from disk
+func (r Repo) Delete() error {
+	if err := os.RemoveAll(r.path); err != nil {
+		return err
+	}
+	r.pruneNamespaces()
+	return nil
+}
+
+// pruneNamespaces removes the Repo's namespaces once they are empty, namespaces with meta are kept
+func (r Repo) pruneNamespaces() {
+	for ns := r.Namespace(); ns != ""; ns, _ = splitName(ns) {
+		if os.Remove(filepath.Join(r.dir, filepath.FromSlash(ns))) != nil {
+			return
+		}
+	}
+}
+
+// SetDefaultBranch points HEAD at an existing branch
+func (r Repo) SetDefaultBranch(branch string) error {
+	repo, err := r.Git()
+	if err != nil {
+		return err
+	}
+	name := plumbing.NewBranchReferenceName(branch)
+	if _, err := repo.Reference(name, false); err != nil {
+		return err
+	}
+	return repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, name))
+}
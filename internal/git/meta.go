@@ -12,9 +12,15 @@ import (
 
 // RepoMeta is the meta information a Repo can have
 type RepoMeta struct {
-	Description string `json:"description"`
-	Private     bool   `json:"private"`
-	Tags        TagSet `json:"tags"`
+	Description string         `json:"description"`
+	Private     bool           `json:"private"`
+	Tags        TagSet         `json:"tags"`
+	Readers     []string       `json:"readers,omitempty"`
+	Writers     []string       `json:"writers,omitempty"`
+	Webhooks    []Webhook      `json:"webhooks,omitempty"`
+	Mirror      *Mirror        `json:"mirror,omitempty"`
+	PushMirrors []PushMirror   `json:"push_mirrors,omitempty"`
+	Protected   []ProtectedRef `json:"protected,omitempty"`
 }
 
 // TagSet is a Set of tags
@@ -54,8 +60,7 @@ func (t TagSet) MarshalJSON() ([]byte, error) {
 // UnmarshalJSON implements [json.Unmarshaler]
 func (t *TagSet) UnmarshalJSON(b []byte) error {
 	if *t == nil {
-		ts := make(TagSet)
-		t = &ts
+		*t = make(TagSet)
 	}
 	var s []string
 	if err := json.Unmarshal(b, &s); err != nil {
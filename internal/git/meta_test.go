@@ -50,4 +50,9 @@ func TestTagSet(t *testing.T) {
 	assert.True(t, set.Contains("foo"))
 	assert.True(t, set.Contains("bar"))
 	assert.True(t, set.Contains("baz"))
+
+	var meta RepoMeta
+	err = json.Unmarshal([]byte(`{"tags":["foo"]}`), &meta)
+	assert.NoError(t, err)
+	assert.True(t, meta.Tags.Contains("foo"))
 }
@@ -0,0 +1,142 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+)
+
+var (
+	// DefaultMirrorInterval is how often a Mirror is synced when it doesn't set an interval
+	DefaultMirrorInterval = 8 * time.Hour
+	// MirrorTimeout is the timeout of a single sync
+	MirrorTimeout = 10 * time.Minute
+)
+
+// Mirror makes a Repo a pull mirror of an upstream repo
+type Mirror struct {
+	URL string `json:"url"`
+	// Interval is how often to sync, as a duration such as "1h"
+	Interval string `json:"interval,omitempty"`
+}
+
+// Every returns how often the mirror should be synced
+func (m Mirror) Every() time.Duration {
+	d, err := time.ParseDuration(m.Interval)
+	if err != nil || d <= 0 {
+		return DefaultMirrorInterval
+	}
+	return d
+}
+
+// DisplayURL returns the upstream URL without any credentials, for showing to users
+func (m Mirror) DisplayURL() string {
+	u, err := url.Parse(m.URL)
+	if err != nil || u.User == nil {
+		return m.URL
+	}
+	u.User = nil
+	return u.String()
+}
+
+// mirrorRefSpecs mirrors all branches and tags, overwriting local changes
+var mirrorRefSpecs = []config.RefSpec{
+	"+refs/heads/*:refs/heads/*",
+	"+refs/tags/*:refs/tags/*",
+}
+
+// MirrorStatus is the result of the last sync of a mirror
+type MirrorStatus struct {
+	When  time.Time `json:"when"`
+	Error string    `json:"error,omitempty"`
+}
+
+func (r Repo) mirrorStatusPath() string {
+	return filepath.Join(r.path, "ugit-mirror.json")
+}
+
+// MirrorStatus returns the result of the last sync, the zero value if the Repo was never synced
+func (r Repo) MirrorStatus() (MirrorStatus, error) {
+	var status MirrorStatus
+	fi, err := os.Open(r.mirrorStatusPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return status, nil
+		}
+		return status, err
+	}
+	defer fi.Close()
+	if err := json.NewDecoder(fi).Decode(&status); err != nil {
+		return MirrorStatus{}, err
+	}
+	return status, nil
+}
+
+// MirrorDue returns whether the Repo is a mirror that should be synced
+func (r Repo) MirrorDue() bool {
+	if r.Meta.Mirror == nil {
+		return false
+	}
+	status, err := r.MirrorStatus()
+	if err != nil {
+		return true
+	}
+	return time.Since(status.When) >= r.Meta.Mirror.Every()
+}
+
+// SyncMirror fetches all branches and tags from the upstream, pruning any that were deleted, and records the result
+func (r Repo) SyncMirror(ctx context.Context) error {
+	if r.Meta.Mirror == nil {
+		return errors.New("repo is not a mirror")
+	}
+
+	err := r.syncMirror(ctx)
+	status := MirrorStatus{
+		When: time.Now(),
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	fi, ferr := os.Create(r.mirrorStatusPath())
+	if ferr != nil {
+		return errors.Join(err, ferr)
+	}
+	defer fi.Close()
+	return errors.Join(err, json.NewEncoder(fi).Encode(status))
+}
+
+func (r Repo) syncMirror(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, MirrorTimeout)
+	defer cancel()
+
+	repo, err := r.Git()
+	if err != nil {
+		return err
+	}
+	remote, err := repo.CreateRemoteAnonymous(&config.RemoteConfig{
+		Name: "anonymous",
+		URLs: []string{r.Meta.Mirror.URL},
+	})
+	if err != nil {
+		return err
+	}
+	if err := remote.FetchContext(ctx, &git.FetchOptions{
+		RefSpecs: mirrorRefSpecs,
+		Tags:     git.NoTags,
+		Force:    true,
+		Prune:    true,
+	}); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return err
+	}
+
+	// A sync is a receive as far as the rest of ugit is concerned
+	return r.AfterReceive()
+}
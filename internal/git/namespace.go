@@ -0,0 +1,179 @@
+package git
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Namespace is a directory of repos and other namespaces, e.g. "group" for "group/repo.git"
+type Namespace struct {
+	path string
+	name string
+	Meta NamespaceMeta
+	// inherited is whether a namespace this one is in is private
+	inherited bool
+}
+
+// NamespaceMeta is the meta information a Namespace can have
+// A private namespace makes every repo in it private, regardless of the repo's own meta
+type NamespaceMeta struct {
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
+}
+
+// Name returns the name of the Namespace relative to the repo dir, e.g. "group/sub"
+func (n Namespace) Name() string {
+	return n.name
+}
+
+// Path returns the path to the Namespace
+func (n Namespace) Path() string {
+	return n.path
+}
+
+// Private returns whether the Namespace is private, either itself or because a namespace it is in is private
+func (n Namespace) Private() bool {
+	return n.Meta.Private || n.inherited
+}
+
+// NewNamespace constructs an existing Namespace given a dir and name
+// Namespaces don't need a meta file, without one they are public and have no description
+func NewNamespace(dir, name string) (*Namespace, error) {
+	n, err := loadNamespace(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	parent, _ := splitName(n.name)
+	n.inherited = namespacePrivate(dir, parent)
+	return n, nil
+}
+
+// loadNamespace constructs a Namespace from its own meta, without looking at the namespaces it is in
+func loadNamespace(dir, name string) (*Namespace, error) {
+	name, err := cleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	n := &Namespace{
+		path: filepath.Join(dir, filepath.FromSlash(name)),
+		name: name,
+	}
+
+	fi, err := os.Stat(n.path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() || strings.HasSuffix(n.path, ".git") {
+		return nil, fmt.Errorf("%q is not a namespace: %w", name, fs.ErrNotExist)
+	}
+
+	data, err := os.ReadFile(n.metaPath())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return n, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &n.Meta); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n Namespace) metaPath() string {
+	return filepath.Join(n.path, "ugit.json")
+}
+
+// SaveMeta saves the meta info of a Namespace
+func (n Namespace) SaveMeta() error {
+	fi, err := os.Create(n.metaPath())
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+	return json.NewEncoder(fi).Encode(n.Meta)
+}
+
+// namespacePrivate returns whether a namespace, or any namespace it is in, is private
+func namespacePrivate(dir, name string) bool {
+	for ns := range parentNamespaces(name) {
+		n, err := loadNamespace(dir, ns)
+		if err == nil && n.Meta.Private {
+			return true
+		}
+	}
+	return false
+}
+
+// parentNamespaces yields a namespace and each namespace it is in, e.g. "a", "a/b" and "a/b/c" for "a/b/c"
+func parentNamespaces(name string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		if name == "" {
+			return
+		}
+		for idx := range len(name) {
+			if name[idx] == '/' && !yield(name[:idx]) {
+				return
+			}
+		}
+		yield(name)
+	}
+}
+
+// WalkRepos calls fn with the name of every repo in dir, including repos nested in namespaces, in lexical order
+func WalkRepos(dir string, fn func(name string) error) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || p == dir {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		// The hooks live next to the top-level repos
+		if rel == "hooks" || strings.HasPrefix(d.Name(), ".") {
+			return fs.SkipDir
+		}
+		if name, ok := strings.CutSuffix(filepath.ToSlash(rel), ".git"); ok {
+			if err := fn(name); err != nil {
+				return err
+			}
+			return fs.SkipDir
+		}
+		return nil
+	})
+}
+
+// cleanName normalizes a repo or namespace name to a slash-separated path without the .git suffix
+func cleanName(name string) (string, error) {
+	name = path.Clean(strings.Trim(filepath.ToSlash(name), "/"))
+	name = strings.TrimSuffix(name, ".git")
+	if name == "" || name == "." || !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidRepoName, name)
+	}
+	return name, nil
+}
+
+// splitName splits a repo name into its namespace and base name
+func splitName(name string) (string, string) {
+	idx := strings.LastIndex(name, "/")
+	if idx < 0 {
+		return "", name
+	}
+	return name[:idx], name[idx+1:]
+}
+
+// repoPath returns the on-disk path of a cleaned repo name
+func repoPath(dir, name string) string {
+	return filepath.Join(dir, filepath.FromSlash(name)+".git")
+}
@@ -0,0 +1,73 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// HookTimeout is how long a hook command may run before it is killed
+var HookTimeout = time.Minute
+
+// PostReceive is everything that happens after a successful push, regardless of protocol
+type PostReceive struct {
+	// Catalog, if set, is invalidated for the repo
+	Catalog *Catalog
+	// PushMirrors, if set, is sent the repo
+	PushMirrors *PushMirrorQueue
+	// Webhooks, if set, is sent the updated refs
+	Webhooks *WebhookQueue
+	// Commands are run in the background with the updated refs on stdin, see [Repo.RunHook]
+	Commands []string
+}
+
+// Run runs everything for a push to a Repo, without waiting on anything slow
+func (p PostReceive) Run(repo *Repo, pusher Pusher, refs []RefUpdate) {
+	if p.Catalog != nil {
+		p.Catalog.Invalidate(repo.Name())
+	}
+	if p.PushMirrors != nil {
+		p.PushMirrors.Enqueue(repo)
+	}
+	if p.Webhooks != nil {
+		p.Webhooks.Enqueue(repo, pusher, refs)
+	}
+
+	// Updated refs are formatted the same as for a git post-receive hook, "<old-sha> <new-sha> <ref-name>" per line
+	var stdin strings.Builder
+	for _, ref := range refs {
+		fmt.Fprintf(&stdin, "%s %s %s\n", ref.Old, ref.New, ref.Name)
+	}
+	for _, cmd := range p.Commands {
+		go repo.RunHook(cmd, pusher, stdin.String())
+	}
+}
+
+// RunHook runs a hook command for the Repo with sh, logging any failure.
+//
+// Commands are run with the following environment:
+//
+//	UGIT_REPO        the repo name, e.g. "ugit"
+//	UGIT_REPO_PATH   the path to the repo on disk
+//	UGIT_FINGERPRINT the SHA256 fingerprint of the SSH key used, if any
+//	UGIT_USER        the name of the user, if any
+func (r Repo) RunHook(command string, user Pusher, stdin string) {
+	ctx, cancel := context.WithTimeout(context.Background(), HookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("UGIT_REPO=%s", r.Name()),
+		fmt.Sprintf("UGIT_REPO_PATH=%s", r.path),
+		fmt.Sprintf("UGIT_FINGERPRINT=%s", user.Fingerprint),
+		fmt.Sprintf("UGIT_USER=%s", user.Name),
+	)
+	cmd.Stdin = strings.NewReader(stdin)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		slog.Error("hook failed", "command", command, "repo", r.Name(), "error", err, "output", string(out))
+	}
+}
@@ -0,0 +1,243 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// zeroSHA is the old or new value of a RefUpdate that creates or deletes a ref
+var zeroSHA = plumbing.ZeroHash.String()
+
+// ProtectedRef is a rule restricting how refs matching Pattern can be updated
+type ProtectedRef struct {
+	// Pattern is a glob matched against full ref names, e.g. "refs/tags/v*"
+	// A pattern not starting with "refs/" matches branches, e.g. "main" or "release/*"
+	Pattern string `json:"pattern"`
+	// NoForcePush rejects non-fast-forward updates, which is any update a client has to force
+	NoForcePush bool `json:"no_force_push,omitempty"`
+	// NoDelete rejects deleting the ref
+	NoDelete bool `json:"no_delete,omitempty"`
+	// RequireSigned rejects pushing commits without a signature verified against the configured SigningKeys
+	RequireSigned bool `json:"require_signed,omitempty"`
+	// Pushers, if set, are the only user names and/or SSH key fingerprints that can update the ref
+	Pushers []string `json:"pushers,omitempty"`
+}
+
+// Matches returns whether a full ref name is protected by the rule
+func (p ProtectedRef) Matches(ref string) bool {
+	pattern := p.Pattern
+	if !strings.HasPrefix(pattern, "refs/") {
+		pattern = "refs/heads/" + pattern
+	}
+	ok, err := path.Match(pattern, ref)
+	return err == nil && ok
+}
+
+// ProtectionError is the reason a ref update was rejected by a ProtectedRef
+type ProtectionError struct {
+	Ref     string
+	Pattern string
+	Reason  string
+}
+
+// Error implements [error]
+func (p ProtectionError) Error() string {
+	return fmt.Sprintf("%s is protected by %q: %s", p.Ref, p.Pattern, p.Reason)
+}
+
+// refHistory answers the questions protection rules ask about the commits of a push,
+// which depends on where the protocol keeps objects before the refs are updated
+type refHistory interface {
+	// isAncestor returns whether the commit old points to is an ancestor of the commit new points to
+	isAncestor(old, new string) (bool, error)
+	// unverifiedCommit returns the first commit reachable from new but not old without a verified signature, if any
+	// If old is the zero SHA, commits reachable from any existing ref are excluded instead
+	unverifiedCommit(old, new string, verifier *Verifier) (*Commit, error)
+}
+
+// unverified verifies a pushed commit, returning it if its signature isn't verified
+func unverified(obj *object.Commit, verifier *Verifier) *Commit {
+	commit := newCommit(obj)
+	commit.Verify(verifier)
+	if commit.Verification.Status == SignatureVerified {
+		return nil
+	}
+	return &commit
+}
+
+// checkRefUpdate checks an update against the protected refs of the Repo, returning a ProtectionError if it isn't allowed
+func (r Repo) checkRefUpdate(history refHistory, verifier *Verifier, pusher Pusher, update RefUpdate) error {
+	for _, rule := range r.Meta.Protected {
+		if !rule.Matches(update.Name) {
+			continue
+		}
+		reject := func(reason string, args ...any) error {
+			return ProtectionError{Ref: update.Name, Pattern: rule.Pattern, Reason: fmt.Sprintf(reason, args...)}
+		}
+
+		if len(rule.Pushers) > 0 && !slices.ContainsFunc([]string{pusher.Name, pusher.Fingerprint}, func(id string) bool {
+			return id != "" && slices.Contains(rule.Pushers, id)
+		}) {
+			return reject("you are not allowed to push to it")
+		}
+
+		switch {
+		case update.New == zeroSHA:
+			if rule.NoDelete {
+				return reject("deletion is not allowed")
+			}
+			continue
+		case update.Old != zeroSHA && rule.NoForcePush:
+			ff, err := history.isAncestor(update.Old, update.New)
+			if err != nil {
+				return err
+			}
+			if !ff {
+				return reject("force-push is not allowed, only fast-forward updates")
+			}
+		}
+
+		if rule.RequireSigned {
+			commit, err := history.unverifiedCommit(update.Old, update.New, verifier)
+			if err != nil {
+				return err
+			}
+			if commit != nil {
+				if commit.Verification.Status == Unsigned {
+					return reject("commit %s is not signed", commit.SHA)
+				}
+				return reject("commit %s is not signed by a trusted key (%s)", commit.SHA, strings.ToLower(commit.Verification.Status.String()))
+			}
+		}
+	}
+	return nil
+}
+
+// gogitHistory is a refHistory for a repo whose pushed objects are already in its object storage
+type gogitHistory struct {
+	repo *git.Repository
+}
+
+func (g gogitHistory) commit(sha string) (*object.Commit, error) {
+	obj, err := g.repo.Object(plumbing.AnyObject, plumbing.NewHash(sha))
+	if err != nil {
+		return nil, err
+	}
+	// Tags are peeled, so annotated tags can be protected the same as branches
+	for {
+		switch o := obj.(type) {
+		case *object.Commit:
+			return o, nil
+		case *object.Tag:
+			obj, err = o.Object()
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%s does not point to a commit", sha)
+		}
+	}
+}
+
+func (g gogitHistory) isAncestor(old, new string) (bool, error) {
+	oldCommit, err := g.commit(old)
+	if err != nil {
+		return false, err
+	}
+	newCommit, err := g.commit(new)
+	if err != nil {
+		return false, err
+	}
+	return oldCommit.IsAncestor(newCommit)
+}
+
+func (g gogitHistory) unverifiedCommit(old, new string, verifier *Verifier) (*Commit, error) {
+	var heads []string
+	if old != zeroSHA {
+		heads = append(heads, old)
+	} else {
+		refs, err := g.repo.References()
+		if err != nil {
+			return nil, err
+		}
+		if err := refs.ForEach(func(ref *plumbing.Reference) error {
+			if ref.Type() == plumbing.HashReference {
+				heads = append(heads, ref.Hash().String())
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[plumbing.Hash]bool)
+	for _, head := range heads {
+		commit, err := g.commit(head)
+		if err != nil {
+			// Refs to other objects don't have history to exclude
+			continue
+		}
+		if err := object.NewCommitPreorderIter(commit, seen, nil).ForEach(func(c *object.Commit) error {
+			seen[c.Hash] = true
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	commit, err := g.commit(new)
+	if err != nil {
+		return nil, err
+	}
+	var found *Commit
+	err = object.NewCommitPreorderIter(commit, seen, nil).ForEach(func(c *object.Commit) error {
+		if found = unverified(c, verifier); found != nil {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, storer.ErrStop) {
+		return nil, err
+	}
+	return found, nil
+}
+
+// CheckRefUpdates checks a push against the protected refs of the Repo, calling reject with each update that isn't allowed.
+// Signatures are verified against keys, which are only loaded if a rule requires signed commits.
+// The returned error is only for failures to check, in which case the push should be rejected as a whole.
+func (r Repo) CheckRefUpdates(ctx context.Context, pusher Pusher, keys SigningKeys, refs []RefUpdate, reject func(RefUpdate, ProtectionError)) error {
+	if len(r.Meta.Protected) == 0 {
+		return nil
+	}
+	history, err := newRefHistory(ctx, r.path)
+	if err != nil {
+		return err
+	}
+	var verifier *Verifier
+	if slices.ContainsFunc(r.Meta.Protected, func(rule ProtectedRef) bool { return rule.RequireSigned }) {
+		verifier, err = keys.Load()
+		if err != nil {
+			return fmt.Errorf("could not load signing keys: %w", err)
+		}
+	}
+	for _, update := range refs {
+		err := r.checkRefUpdate(history, verifier, pusher, update)
+		var perr ProtectionError
+		switch {
+		case errors.As(err, &perr):
+			reject(update, perr)
+		case err != nil:
+			return fmt.Errorf("could not check %s: %w", update.Name, err)
+		}
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package git
 
 import (
 	"context"
+	"errors"
 	"io"
+	"log/slog"
 	"strconv"
 	"strings"
 
@@ -18,13 +20,33 @@ type ReadWriteContexter interface {
 	Context() context.Context
 }
 
+// Protocoler is the interface for serving git over HTTP and SSH
 type Protocoler interface {
-	HTTPInfoRefs(ReadWriteContexter) error
+	HTTPInfoRefs(ReadWriteContexter, string) error
 	HTTPUploadPack(ReadWriteContexter) error
+	HTTPReceivePack(ReadWriteContexter, *Repo) error
 	SSHUploadPack(ReadWriteContexter) error
 	SSHReceivePack(ReadWriteContexter, *Repo) error
 }
 
+// AfterReceive does the housekeeping needed after a successful receive-pack, regardless of protocol
+func (r Repo) AfterReceive() error {
+	if _, err := r.DefaultBranch(); err != nil {
+		return err
+	}
+	// Needed for git dumb http server
+	if err := UpdateServerInfo(r.path); err != nil {
+		return err
+	}
+	// Indexing a large push can take a while, and a stale index only makes searches slower, so it doesn't hold up the push
+	go func() {
+		if err := r.UpdateSearchIndex(); err != nil {
+			slog.Error("could not update search index", "repo", r.Name(), "error", err)
+		}
+	}()
+	return nil
+}
+
 // UpdateServerInfo handles updating server info for the git repo
 func UpdateServerInfo(repo string) error {
 	r, err := git.PlainOpen(repo)
@@ -35,11 +57,22 @@ func UpdateServerInfo(repo string) error {
 	return serverinfo.UpdateServerInfo(r.Storer, fs)
 }
 
+// ErrPushOptionsDenied is returned by HandlePushOptions when a pusher without AdminAccess tries to change a [Repo]
+var ErrPushOptionsDenied = errors.New("push options changing the repository require admin access, they were ignored")
+
 // HandlePushOptions handles all relevant push options for a [Repo] and saves the new [RepoMeta]
-func HandlePushOptions(repo *Repo, opts []*packp.Option) error {
+// The options change settings of the repo, so they are ignored unless the pusher has AdminAccess
+func HandlePushOptions(repo *Repo, access AccessLevel, opts []*packp.Option) error {
 	var changed bool
 	for _, opt := range opts {
-		switch strings.ToLower(opt.Key) {
+		key := strings.ToLower(opt.Key)
+		switch key {
+		case "desc", "description", "private", "tags":
+			if access < AdminAccess {
+				return ErrPushOptionsDenied
+			}
+		}
+		switch key {
 		case "desc", "description":
 			changed = repo.Meta.Description != opt.Value
 			repo.Meta.Description = opt.Value
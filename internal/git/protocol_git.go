@@ -3,61 +3,202 @@
 package git
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/format/pktline"
+	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
 var RequiresHook = true
 
-type CmdProtocol string
+// CmdProtocol shells out to git for a repo, running the hooks in the repo dir
+type CmdProtocol struct {
+	dir  string
+	name string
+}
 
-func NewProtocol(repoPath string) (Protocoler, error) {
-	return CmdProtocol(repoPath), nil
+// NewProtocol constructs a CmdProtocol for the repo with the given name in dir
+func NewProtocol(dir, name string) (Protocoler, error) {
+	name, err := cleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	return CmdProtocol{dir: dir, name: name}, nil
 }
 
-func (c CmdProtocol) HTTPInfoRefs(ctx ReadWriteContexter) error {
+func (c CmdProtocol) HTTPInfoRefs(ctx ReadWriteContexter, service string) error {
+	command, ok := strings.CutPrefix(service, "git-")
+	if !ok || (command != "upload-pack" && command != "receive-pack") {
+		return fmt.Errorf("unknown service %q", service)
+	}
 	pkt := pktline.NewEncoder(ctx)
-	if err := pkt.EncodeString("# service=git-upload-pack"); err != nil {
+	if err := pkt.EncodeString("# service=" + service); err != nil {
 		return err
 	}
 	if err := pkt.Flush(); err != nil {
 		return err
 	}
-	return gitService(ctx, "upload-pack", string(c), "--stateless-rpc", "--advertise-refs")
+	return gitService(ctx, command, c, "--stateless-rpc", "--advertise-refs")
 }
 
 func (c CmdProtocol) HTTPUploadPack(ctx ReadWriteContexter) error {
-	return gitService(ctx, "upload-pack", string(c), "--stateless-rpc")
+	return gitService(ctx, "upload-pack", c, "--stateless-rpc")
+}
+
+func (c CmdProtocol) HTTPReceivePack(ctx ReadWriteContexter, _ *Repo) error {
+	return gitService(ctx, "receive-pack", c, "--stateless-rpc")
 }
 
 func (c CmdProtocol) SSHUploadPack(ctx ReadWriteContexter) error {
-	return gitService(ctx, "upload-pack", string(c))
+	return gitService(ctx, "upload-pack", c)
 }
 
 func (c CmdProtocol) SSHReceivePack(ctx ReadWriteContexter, _ *Repo) error {
-	return gitService(ctx, "receive-pack", string(c))
+	return gitService(ctx, "receive-pack", c)
 }
 
-func gitService(ctx ReadWriteContexter, command, repoDir string, args ...string) error {
+func gitService(ctx ReadWriteContexter, command string, c CmdProtocol, args ...string) error {
+	repoDir := repoPath(c.dir, c.name)
 	cmd := exec.CommandContext(ctx.Context(), "git")
 	cmd.Args = append(cmd.Args, []string{
 		"-c", "protocol.version=2",
 		"-c", "uploadpack.allowFilter=true",
 		"-c", "receive.advertisePushOptions=true",
-		"-c", fmt.Sprintf("core.hooksPath=%s", filepath.Join(filepath.Dir(repoDir), "hooks")),
+		"-c", fmt.Sprintf("core.hooksPath=%s", filepath.Join(c.dir, "hooks")),
 		command,
 	}...)
 	if len(args) > 0 {
 		cmd.Args = append(cmd.Args, args...)
 	}
 	cmd.Args = append(cmd.Args, repoDir)
-	cmd.Env = append(os.Environ(), fmt.Sprintf("UGIT_REPODIR=%s", repoDir), "GIT_PROTOCOL=version=2")
+	pusher := PusherFromContext(ctx.Context())
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("UGIT_REPODIR=%s", c.dir),
+		fmt.Sprintf("UGIT_REPO=%s", c.name),
+		fmt.Sprintf("UGIT_PUSHER_NAME=%s", pusher.Name),
+		fmt.Sprintf("UGIT_PUSHER_FINGERPRINT=%s", pusher.Fingerprint),
+		fmt.Sprintf("UGIT_PUSHER_ACCESS=%d", pusher.Access),
+		"GIT_PROTOCOL=version=2",
+	)
+	cmd.Env = append(cmd.Env, signingEnviron(SigningKeysFromContext(ctx.Context()))...)
 	cmd.Stdin = ctx
 	cmd.Stdout = ctx
 
 	return cmd.Run()
 }
+
+// signingEnviron passes SigningKeys to the pre-receive hook, which runs in the repo so paths are made absolute
+// Lists are separated by [filepath.ListSeparator]
+func signingEnviron(keys SigningKeys) []string {
+	abs := func(paths ...string) string {
+		for idx, p := range paths {
+			if p == "" {
+				continue
+			}
+			if a, err := filepath.Abs(p); err == nil {
+				paths[idx] = a
+			}
+		}
+		return strings.Join(paths, string(filepath.ListSeparator))
+	}
+	return []string{
+		fmt.Sprintf("UGIT_SIGNING_GPG_KEYS=%s", abs(slices.Clone(keys.GPGKeys)...)),
+		fmt.Sprintf("UGIT_SIGNING_ALLOWED_SIGNERS=%s", abs(keys.AllowedSigners)),
+		fmt.Sprintf("UGIT_SIGNING_AUTHORIZED_KEYS=%s", abs(slices.Clone(keys.AuthorizedKeys)...)),
+	}
+}
+
+// cmdHistory is a refHistory that shells out to git, so a pre-receive hook can see
+// the pushed objects git keeps in quarantine until the hook accepts them
+type cmdHistory struct {
+	ctx  context.Context
+	path string
+}
+
+func newRefHistory(ctx context.Context, path string) (refHistory, error) {
+	return cmdHistory{ctx: ctx, path: path}, nil
+}
+
+func (c cmdHistory) git(args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(c.ctx, "git", args...)
+	cmd.Dir = c.path
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+func (c cmdHistory) isAncestor(old, new string) (bool, error) {
+	err := c.git("merge-base", "--is-ancestor", old, new).Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (c cmdHistory) unverifiedCommit(old, new string, verifier *Verifier) (*Commit, error) {
+	exclude := []string{"--not", "--all"}
+	if old != zeroSHA {
+		exclude = []string{"^" + old}
+	}
+	shas, err := c.git(append([]string{"rev-list", new}, exclude...)...).Output()
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(shas)) == 0 {
+		return nil, nil
+	}
+
+	// Each object is "<sha> <type> <size>" followed by its content and a newline
+	cmd := c.git("cat-file", "--batch")
+	cmd.Stdin = bytes.NewReader(shas)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(bytes.NewReader(out))
+	for {
+		header, err := r.ReadString('\n')
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 3 || fields[1] != "commit" {
+			return nil, fmt.Errorf("unexpected object %q", strings.TrimSpace(header))
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		obj := &plumbing.MemoryObject{}
+		obj.SetType(plumbing.CommitObject)
+		if _, err := io.CopyN(obj, r, size); err != nil {
+			return nil, err
+		}
+		if _, err := r.Discard(1); err != nil {
+			return nil, err
+		}
+
+		commit := &object.Commit{}
+		if err := commit.Decode(obj); err != nil {
+			return nil, err
+		}
+		if found := unverified(commit, verifier); found != nil {
+			return found, nil
+		}
+	}
+}
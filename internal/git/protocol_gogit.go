@@ -4,10 +4,16 @@ package git
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
 
 	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
 	"github.com/go-git/go-git/v5/plumbing/format/pktline"
 	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
 	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
@@ -24,13 +30,17 @@ type Protocol struct {
 	server   transport.Transport
 }
 
-// NewProtocol constructs a Protocol for a given repo
-func NewProtocol(repoPath string) (Protocoler, error) {
+// NewProtocol constructs a Protocol for the repo with the given name in dir
+func NewProtocol(dir, name string) (Protocoler, error) {
+	name, err := cleanName(name)
+	if err != nil {
+		return Protocol{}, err
+	}
 	endpoint, err := transport.NewEndpoint("/")
 	if err != nil {
 		return Protocol{}, err
 	}
-	fs := osfs.New(repoPath)
+	fs := osfs.New(repoPath(dir, name))
 	loader := server.NewFilesystemLoader(fs)
 	gitServer := server.NewServer(loader)
 	return Protocol{
@@ -40,20 +50,37 @@ func NewProtocol(repoPath string) (Protocoler, error) {
 }
 
 // HTTPInfoRefs handles the inforef part of the HTTP protocol
-func (p Protocol) HTTPInfoRefs(rwc ReadWriteContexter) error {
-	session, err := p.server.NewUploadPackSession(p.endpoint, nil)
+func (p Protocol) HTTPInfoRefs(rwc ReadWriteContexter, service string) error {
+	var session advertiser
+	var err error
+	switch service {
+	case "git-upload-pack":
+		session, err = p.server.NewUploadPackSession(p.endpoint, nil)
+	case "git-receive-pack":
+		session, err = p.server.NewReceivePackSession(p.endpoint, nil)
+	default:
+		return fmt.Errorf("unknown service %q", service)
+	}
 	if err != nil {
 		return err
 	}
 	defer ioutil.CheckClose(rwc, &err)
-	return p.infoRefs(rwc, session, "# service=git-upload-pack")
+	return p.infoRefs(rwc, session, "# service="+service)
+}
+
+// advertiser is the common interface of upload and receive pack sessions
+type advertiser interface {
+	AdvertisedReferencesContext(context.Context) (*packp.AdvRefs, error)
 }
 
-func (p Protocol) infoRefs(rwc ReadWriteContexter, session transport.UploadPackSession, prefix string) error {
+func (p Protocol) infoRefs(rwc ReadWriteContexter, session advertiser, prefix string) error {
 	ar, err := session.AdvertisedReferencesContext(rwc.Context())
 	if err != nil {
 		return err
 	}
+	if _, ok := session.(transport.ReceivePackSession); ok {
+		setReceivePackCapabilities(ar)
+	}
 
 	if prefix != "" {
 		ar.Prefix = [][]byte{
@@ -110,8 +137,22 @@ func (p Protocol) uploadPack(rwc ReadWriteContexter, ssh bool) error {
 	return nil
 }
 
+func setReceivePackCapabilities(ar *packp.AdvRefs) {
+	_ = ar.Capabilities.Set(capability.PushOptions)
+	_ = ar.Capabilities.Set("no-thin")
+}
+
+// HTTPReceivePack handles the receive-pack process for HTTP
+func (p Protocol) HTTPReceivePack(rwc ReadWriteContexter, repo *Repo) error {
+	return p.receivePack(rwc, repo, false)
+}
+
 // SSHReceivePack handles the receive-pack process for SSH
 func (p Protocol) SSHReceivePack(rwc ReadWriteContexter, repo *Repo) error {
+	return p.receivePack(rwc, repo, true)
+}
+
+func (p Protocol) receivePack(rwc ReadWriteContexter, repo *Repo, ssh bool) error {
 	buf := bufio.NewReader(rwc)
 
 	session, err := p.server.NewReceivePackSession(p.endpoint, nil)
@@ -119,15 +160,17 @@ func (p Protocol) SSHReceivePack(rwc ReadWriteContexter, repo *Repo) error {
 		return err
 	}
 
+	// The advertisement is needed even for stateless HTTP, as it sets the capabilities of the session
 	ar, err := session.AdvertisedReferencesContext(rwc.Context())
 	if err != nil {
 		return fmt.Errorf("internal error in advertised references: %w", err)
 	}
-	_ = ar.Capabilities.Set(capability.PushOptions)
-	_ = ar.Capabilities.Set("no-thin")
+	setReceivePackCapabilities(ar)
 
-	if err := ar.Encode(rwc); err != nil {
-		return fmt.Errorf("error in advertised references encoding: %w", err)
+	if ssh {
+		if err := ar.Encode(rwc); err != nil {
+			return fmt.Errorf("error in advertised references encoding: %w", err)
+		}
 	}
 
 	req := packp.NewReferenceUpdateRequest()
@@ -139,12 +182,8 @@ func (p Protocol) SSHReceivePack(rwc ReadWriteContexter, repo *Repo) error {
 		}
 	}
 
-	// FIXME also a hack, if the next bytes are PACK then we have a packfile, otherwise assume it's push options
-	peek, err := buf.Peek(4)
-	if err != nil {
-		return err
-	}
-	if string(peek) != "PACK" {
+	// go-git doesn't decode push options, which are only sent if the client asked for the capability
+	if req.Capabilities.Supports(capability.PushOptions) {
 		s := pktline.NewScanner(buf)
 		for s.Scan() {
 			val := string(s.Bytes())
@@ -155,6 +194,9 @@ func (p Protocol) SSHReceivePack(rwc ReadWriteContexter, repo *Repo) error {
 				return s.Err()
 			}
 			parts := strings.SplitN(val, "=", 2)
+			if len(parts) != 2 {
+				parts = append(parts, "")
+			}
 			req.Options = append(req.Options, &packp.Option{
 				Key:   parts[0],
 				Value: parts[1],
@@ -162,11 +204,7 @@ func (p Protocol) SSHReceivePack(rwc ReadWriteContexter, repo *Repo) error {
 		}
 	}
 
-	if err := HandlePushOptions(repo, req.Options); err != nil {
-		return fmt.Errorf("could not handle push options: %w", err)
-	}
-
-	// FIXME if there are only delete commands, there is no packfile and ReceivePack will block forever
+	// If there are only delete commands there is no packfile, and reading one would block forever
 	noPack := true
 	for _, c := range req.Commands {
 		if c.Action() != packp.Delete {
@@ -174,8 +212,60 @@ func (p Protocol) SSHReceivePack(rwc ReadWriteContexter, repo *Repo) error {
 			break
 		}
 	}
-	if noPack {
-		req.Packfile = nil
+
+	// The packfile is stored before the refs are updated, so protected refs can be checked against the pushed commits
+	if !noPack {
+		r, err := git.PlainOpen(repo.path)
+		if err != nil {
+			return err
+		}
+		if err := packfile.UpdateObjectStorage(r.Storer, ioutil.NewContextReadCloser(rwc.Context(), req.Packfile)); err != nil {
+			return fmt.Errorf("could not store packfile: %w", err)
+		}
+	}
+	req.Packfile = nil
+
+	pusher := PusherFromContext(rwc.Context())
+	refs := make([]RefUpdate, 0, len(req.Commands))
+	for _, c := range req.Commands {
+		refs = append(refs, RefUpdate{
+			Name: c.Name.String(),
+			Old:  c.Old.String(),
+			New:  c.New.String(),
+		})
+	}
+	rejected := make(map[string]string)
+	if err := repo.CheckRefUpdates(rwc.Context(), pusher, SigningKeysFromContext(rwc.Context()), refs, func(update RefUpdate, perr ProtectionError) {
+		rejected[update.Name] = perr.Error()
+	}); err != nil {
+		slog.Error("could not check protected refs", "repo", repo.Name(), "error", err)
+		for _, update := range refs {
+			rejected[update.Name] = "could not check protected refs"
+		}
+	}
+
+	// Like a declined pre-receive hook, a rejected ref means nothing else in the push is applied
+	if len(rejected) > 0 {
+		rs := packp.NewReportStatus()
+		rs.UnpackStatus = "ok"
+		for _, update := range refs {
+			status, ok := rejected[update.Name]
+			if !ok {
+				status = "rejected with the rest of the push"
+			}
+			rs.CommandStatuses = append(rs.CommandStatuses, &packp.CommandStatus{
+				ReferenceName: plumbing.ReferenceName(update.Name),
+				Status:        status,
+			})
+		}
+		return rs.Encode(rwc)
+	}
+
+	if err := HandlePushOptions(repo, pusher.Access, req.Options); err != nil {
+		if !errors.Is(err, ErrPushOptionsDenied) {
+			return fmt.Errorf("could not handle push options: %w", err)
+		}
+		slog.Info("ignored push options", "repo", repo.Name(), "pusher", pusher.Name, "access", pusher.Access)
 	}
 
 	rs, err := session.ReceivePack(rwc.Context(), req)
@@ -189,3 +279,11 @@ func (p Protocol) SSHReceivePack(rwc ReadWriteContexter, repo *Repo) error {
 
 	return nil
 }
+
+func newRefHistory(_ context.Context, path string) (refHistory, error) {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return nil, err
+	}
+	return gogitHistory{repo: repo}, nil
+}
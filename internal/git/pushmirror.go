@@ -0,0 +1,360 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+var (
+	// PushMirrorAttempts is how many times a push is attempted before giving up until the next push
+	PushMirrorAttempts = 5
+	// PushMirrorBackoff is the wait before the first retry, doubling after each attempt
+	PushMirrorBackoff = time.Minute
+	// PushMirrorTimeout is the timeout of a single push
+	PushMirrorTimeout = 10 * time.Minute
+)
+
+// PushMirror is a remote that a Repo is pushed to after every push
+type PushMirror struct {
+	URL string `json:"url"`
+	// Credential is the name of a Credential given to the PushMirrorQueue
+	Credential string `json:"credential,omitempty"`
+	// Force overwrites refs that have diverged on the remote
+	Force bool `json:"force,omitempty"`
+	// Prune deletes refs on the remote that no longer exist locally
+	Prune bool `json:"prune,omitempty"`
+}
+
+// DisplayURL returns the remote URL without any credentials, for showing to users
+func (p PushMirror) DisplayURL() string {
+	return Mirror{URL: p.URL}.DisplayURL()
+}
+
+// Credential authenticates pushes to a PushMirror
+type Credential struct {
+	Username string
+	Password string
+	// SSHKey is the path to a private key, used instead of a username and password
+	SSHKey string
+}
+
+func (c Credential) auth(remote string) (transport.AuthMethod, error) {
+	if c.SSHKey == "" {
+		return &http.BasicAuth{
+			Username: c.Username,
+			Password: c.Password,
+		}, nil
+	}
+	user := "git"
+	if ep, err := transport.NewEndpoint(remote); err == nil && ep.User != "" {
+		user = ep.User
+	}
+	return ssh.NewPublicKeysFromFile(user, c.SSHKey, "")
+}
+
+// PushMirrorStatus is the result of the last push to a PushMirror
+type PushMirrorStatus struct {
+	When     time.Time `json:"when"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error,omitempty"`
+}
+
+func (r Repo) pushMirrorStatusPath() string {
+	return filepath.Join(r.path, "ugit-push-mirrors.json")
+}
+
+// pushMirrorStatusMu guards reading and writing the push mirror status files
+var pushMirrorStatusMu sync.Mutex
+
+// PushMirrorStatuses returns the result of the last push to each PushMirror, keyed by URL
+func (r Repo) PushMirrorStatuses() (map[string]PushMirrorStatus, error) {
+	pushMirrorStatusMu.Lock()
+	defer pushMirrorStatusMu.Unlock()
+	return r.pushMirrorStatuses()
+}
+
+func (r Repo) pushMirrorStatuses() (map[string]PushMirrorStatus, error) {
+	statuses := make(map[string]PushMirrorStatus)
+	fi, err := os.Open(r.pushMirrorStatusPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return statuses, nil
+		}
+		return nil, err
+	}
+	defer fi.Close()
+	if err := json.NewDecoder(fi).Decode(&statuses); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+func (r Repo) savePushMirrorStatus(remote string, status PushMirrorStatus) error {
+	pushMirrorStatusMu.Lock()
+	defer pushMirrorStatusMu.Unlock()
+
+	statuses, err := r.pushMirrorStatuses()
+	if err != nil {
+		return err
+	}
+	// Forget mirrors that were removed
+	for u := range statuses {
+		if r.pushMirror(u) == nil {
+			delete(statuses, u)
+		}
+	}
+	statuses[remote] = status
+
+	fi, err := os.Create(r.pushMirrorStatusPath())
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+	return json.NewEncoder(fi).Encode(statuses)
+}
+
+func (r Repo) pushMirror(remote string) *PushMirror {
+	for idx, mirror := range r.Meta.PushMirrors {
+		if mirror.URL == remote {
+			return &r.Meta.PushMirrors[idx]
+		}
+	}
+	return nil
+}
+
+// PushToMirror pushes all branches and tags to a PushMirror
+func (r Repo) PushToMirror(ctx context.Context, mirror PushMirror, auth transport.AuthMethod) error {
+	ctx, cancel := context.WithTimeout(ctx, PushMirrorTimeout)
+	defer cancel()
+
+	repo, err := r.Git()
+	if err != nil {
+		return err
+	}
+	remote, err := repo.CreateRemoteAnonymous(&config.RemoteConfig{
+		Name: "anonymous",
+		URLs: []string{mirror.URL},
+	})
+	if err != nil {
+		return err
+	}
+
+	refSpecs := []config.RefSpec{"refs/heads/*:refs/heads/*", "refs/tags/*:refs/tags/*"}
+	if mirror.Prune {
+		// go-git prunes every ref when pruning is combined with force, so deletions are found here instead
+		deletes, err := pruneRefSpecs(ctx, repo, remote, auth)
+		if err != nil {
+			return err
+		}
+		refSpecs = append(refSpecs, deletes...)
+	}
+	if err := remote.PushContext(ctx, &git.PushOptions{
+		RemoteName: "anonymous",
+		RefSpecs:   refSpecs,
+		Auth:       auth,
+		Force:      mirror.Force,
+	}); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return err
+	}
+	return nil
+}
+
+// pruneRefSpecs returns refspecs deleting the branches and tags of a remote that don't exist locally
+func pruneRefSpecs(ctx context.Context, repo *git.Repository, remote *git.Remote, auth transport.AuthMethod) ([]config.RefSpec, error) {
+	remoteRefs, err := remote.ListContext(ctx, &git.ListOptions{Auth: auth})
+	if err != nil {
+		if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var deletes []config.RefSpec
+	for _, ref := range remoteRefs {
+		name := ref.Name()
+		if !name.IsBranch() && !name.IsTag() || strings.HasSuffix(name.String(), "^{}") {
+			continue
+		}
+		if _, err := repo.Reference(name, false); errors.Is(err, plumbing.ErrReferenceNotFound) {
+			deletes = append(deletes, config.RefSpec(":"+name.String()))
+		}
+	}
+	return deletes, nil
+}
+
+// PushMirrorQueue pushes repos to their push mirrors in the background, retrying failures with backoff
+type PushMirrorQueue struct {
+	credentials map[string]Credential
+
+	mu     sync.Mutex
+	jobs   []pushMirrorJob
+	queued map[pushMirrorKey]struct{}
+	wake   chan struct{}
+}
+
+type pushMirrorKey struct {
+	dir  string
+	name string
+	url  string
+}
+
+type pushMirrorJob struct {
+	pushMirrorKey
+	attempt int
+}
+
+// NewPushMirrorQueue returns a PushMirrorQueue, credentials are referenced by name from PushMirror.Credential
+func NewPushMirrorQueue(credentials map[string]Credential) *PushMirrorQueue {
+	return &PushMirrorQueue{
+		credentials: credentials,
+		queued:      make(map[pushMirrorKey]struct{}),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Enqueue queues a push to every PushMirror of a Repo
+func (q *PushMirrorQueue) Enqueue(repo *Repo) {
+	for _, mirror := range repo.Meta.PushMirrors {
+		q.enqueue(pushMirrorJob{
+			pushMirrorKey: pushMirrorKey{dir: repo.dir, name: repo.name, url: mirror.URL},
+			attempt:       1,
+		})
+	}
+}
+
+func (q *PushMirrorQueue) enqueue(job pushMirrorJob) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// A queued push sends whatever the repo looks like once it runs, so there is no need for another
+	if _, ok := q.queued[job.pushMirrorKey]; ok {
+		return
+	}
+	q.queued[job.pushMirrorKey] = struct{}{}
+	q.jobs = append(q.jobs, job)
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *PushMirrorQueue) next() (pushMirrorJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.jobs) == 0 {
+		return pushMirrorJob{}, false
+	}
+	job := q.jobs[0]
+	q.jobs = q.jobs[1:]
+	delete(q.queued, job.pushMirrorKey)
+	return job, true
+}
+
+// Run pushes queued jobs one at a time until ctx is done
+func (q *PushMirrorQueue) Run(ctx context.Context) {
+	for {
+		job, ok := q.next()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.wake:
+			}
+			continue
+		}
+
+		if err := q.push(ctx, job); err != nil {
+			slog.Error("could not push to mirror", "repo", job.name, "url", PushMirror{URL: job.url}.DisplayURL(), "attempt", job.attempt, "error", err)
+			if job.attempt < PushMirrorAttempts {
+				retry := job
+				retry.attempt++
+				time.AfterFunc(PushMirrorBackoff<<(job.attempt-1), func() {
+					q.enqueue(retry)
+				})
+			}
+		}
+	}
+}
+
+func (q *PushMirrorQueue) push(ctx context.Context, job pushMirrorJob) error {
+	// Reload the repo, the mirror may have been changed or removed since the job was queued
+	repo, err := NewRepo(job.dir, job.name)
+	if err != nil {
+		return err
+	}
+	mirror := repo.pushMirror(job.url)
+	if mirror == nil {
+		return nil
+	}
+
+	err = q.pushToMirror(ctx, repo, *mirror)
+	status := PushMirrorStatus{
+		When:     time.Now(),
+		Attempts: job.attempt,
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	return errors.Join(err, repo.savePushMirrorStatus(job.url, status))
+}
+
+func (q *PushMirrorQueue) pushToMirror(ctx context.Context, repo *Repo, mirror PushMirror) error {
+	var auth transport.AuthMethod
+	if mirror.Credential != "" {
+		cred, ok := q.credentials[mirror.Credential]
+		if !ok {
+			return fmt.Errorf("unknown credential %q", mirror.Credential)
+		}
+		var err error
+		auth, err = cred.auth(mirror.URL)
+		if err != nil {
+			return err
+		}
+	}
+	return repo.PushToMirror(ctx, mirror, auth)
+}
+
+// PushMirrorSummary summarizes the statuses of a Repo's push mirrors, e.g. "2 push mirrors, 1 failing"
+// It is empty if the Repo has no push mirrors
+func (r Repo) PushMirrorSummary() string {
+	if len(r.Meta.PushMirrors) == 0 {
+		return ""
+	}
+	statuses, err := r.PushMirrorStatuses()
+	if err != nil {
+		return "push mirror status unknown"
+	}
+	var failing int
+	for _, mirror := range r.Meta.PushMirrors {
+		if statuses[mirror.URL].Error != "" {
+			failing++
+		}
+	}
+
+	noun := "push mirror"
+	if len(r.Meta.PushMirrors) > 1 {
+		noun += "s"
+	}
+	summary := fmt.Sprintf("%d %s", len(r.Meta.PushMirrors), noun)
+	if failing > 0 {
+		summary += fmt.Sprintf(", %d failing", failing)
+	}
+	return summary
+}
@@ -0,0 +1,92 @@
+package git
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// RefUpdate is a reference that was created, updated, or deleted.
+// Created refs have a zero Old hash and deleted refs have a zero New hash.
+type RefUpdate struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// Refs returns a snapshot of all branches and tags in the repo
+func (r Repo) Refs() (map[string]string, error) {
+	repo, err := r.Git()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(map[string]string)
+	if err := iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference || (!ref.Name().IsBranch() && !ref.Name().IsTag()) {
+			return nil
+		}
+		refs[ref.Name().String()] = ref.Hash().String()
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return refs, nil
+}
+
+// DiffRefs returns the RefUpdates between two snapshots from Repo.Refs, sorted by name
+func DiffRefs(before, after map[string]string) []RefUpdate {
+	zero := plumbing.ZeroHash.String()
+	var updates []RefUpdate
+	for name, newHash := range after {
+		oldHash, ok := before[name]
+		if !ok {
+			oldHash = zero
+		}
+		if oldHash != newHash {
+			updates = append(updates, RefUpdate{
+				Name: name,
+				Old:  oldHash,
+				New:  newHash,
+			})
+		}
+	}
+	for name, oldHash := range before {
+		if _, ok := after[name]; !ok {
+			updates = append(updates, RefUpdate{
+				Name: name,
+				Old:  oldHash,
+				New:  zero,
+			})
+		}
+	}
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].Name < updates[j].Name
+	})
+	return updates
+}
+
+// SplitRefPath splits p into a ref and a path within it, e.g. "release/1.0/docs/README.md".
+// Branches and tags can contain slashes, so the longest prefix that resolves is the ref.
+// If no prefix resolves, the ref is the first path element.
+func (r Repo) SplitRefPath(p string) (string, string) {
+	p = strings.Trim(p, "/")
+	first, rest, _ := strings.Cut(p, "/")
+	repo, err := r.Git()
+	if err != nil {
+		return first, rest
+	}
+	for idx := len(p); idx > 0; idx = strings.LastIndex(p[:idx], "/") {
+		if _, err := repo.ResolveRevision(plumbing.Revision(p[:idx])); err == nil {
+			return p[:idx], strings.TrimPrefix(p[idx:], "/")
+		}
+	}
+	return first, rest
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
@@ -13,17 +14,40 @@ import (
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 )
 
 // Repo is a git repository
 type Repo struct {
+	dir  string
+	name string
 	path string
 	Meta RepoMeta
+	// inherited is whether a namespace the Repo is in is private
+	inherited bool
 }
 
-// Name returns the human-friendly name, the dir name without the .git suffix
+// Name returns the human-friendly name, the path relative to the repo dir without the .git suffix, e.g. "group/repo"
 func (r Repo) Name() string {
-	return strings.TrimSuffix(filepath.Base(r.path), ".git")
+	return r.name
+}
+
+// Namespace returns the namespace the Repo is in, or an empty string for a top-level repo
+func (r Repo) Namespace() string {
+	ns, _ := splitName(r.name)
+	return ns
+}
+
+// Private returns whether the Repo is private, either itself or because a namespace it is in is private
+func (r Repo) Private() bool {
+	return r.Meta.Private || r.inherited
+}
+
+// Access returns the AccessLevel granted to the given identities, taking namespace visibility into account
+func (r Repo) Access(identities ...string) AccessLevel {
+	meta := r.Meta
+	meta.Private = r.Private()
+	return meta.Access(identities...)
 }
 
 // Path returns the path to the Repo
@@ -31,17 +55,19 @@ func (r Repo) Path() string {
 	return r.path
 }
 
-// NewRepo constructs a Repo given a dir and name
+// NewRepo constructs a Repo given a dir and name, the name may include namespaces such as "group/repo.git"
 func NewRepo(dir, name string) (*Repo, error) {
-	if !strings.HasSuffix(name, ".git") {
-		name += ".git"
+	name, err := cleanName(name)
+	if err != nil {
+		return nil, err
 	}
 	r := &Repo{
-		path: filepath.Join(dir, name),
+		dir:  dir,
+		name: name,
+		path: repoPath(dir, name),
 	}
 
-	_, err := os.Stat(r.path)
-	if err != nil {
+	if _, err := os.Stat(r.path); err != nil {
 		return nil, err
 	}
 
@@ -60,6 +86,7 @@ func NewRepo(dir, name string) (*Repo, error) {
 	if r.Meta.Tags == nil {
 		r.Meta.Tags = make(TagSet)
 	}
+	r.inherited = namespacePrivate(dir, r.Namespace())
 
 	return r, nil
 }
@@ -104,6 +131,7 @@ func (r Repo) Git() (*git.Repository, error) {
 // Commit is a git commit
 type Commit struct {
 	SHA       string
+	Parents   []string
 	Message   string
 	Signature string
 	Author    string
@@ -111,9 +139,13 @@ type Commit struct {
 	When      time.Time
 
 	// Extra
-	Stats CommitStats
-	Patch string
-	Files []CommitFile
+	Stats        CommitStats
+	Patch        string
+	Files        []CommitFile
+	Verification Verification
+
+	// payload is what Signature signed
+	payload []byte
 }
 
 // CommitStats is the stats of a commit
@@ -160,14 +192,62 @@ func (c Commit) Details() string {
 	return strings.Join(strings.Split(c.Message, "\n")[1:], "\n")
 }
 
+// CombinedDiff can be passed as the parent to CommitDiff to diff a merge commit against all of its parents
+const CombinedDiff = -1
+
+// ErrInvalidParent is returned when a commit does not have the requested parent
+var ErrInvalidParent = errors.New("invalid parent")
+
 // Commit gets a specific commit by SHA, including all commit information
+// The diff is against the first parent, or the empty tree for a root commit
 func (r Repo) Commit(sha string) (Commit, error) {
+	return r.CommitDiff(sha, 0)
+}
+
+// CommitDiff gets a specific commit by SHA, including all commit information,
+// with the diff against the parent at the given index or CombinedDiff
+func (r Repo) CommitDiff(sha string, parent int) (Commit, error) {
 	repo, err := r.Git()
 	if err != nil {
 		return Commit{}, err
 	}
 
-	return commit(repo, sha, true)
+	obj, err := repo.CommitObject(plumbing.NewHash(sha))
+	if err != nil {
+		return Commit{}, err
+	}
+
+	c := newCommit(obj)
+	if parent == CombinedDiff && obj.NumParents() > 1 {
+		c.Stats, c.Patch, c.Files, err = combinedDiff(obj)
+		return c, err
+	}
+	if obj.NumParents() == 0 && parent == 0 {
+		tree, err := obj.Tree()
+		if err != nil {
+			return Commit{}, err
+		}
+		c.Stats, c.Patch, c.Files, err = diff(nil, tree, "", c.SHA)
+		return c, err
+	}
+	if parent < 0 || parent >= obj.NumParents() {
+		return Commit{}, ErrInvalidParent
+	}
+
+	p, err := obj.Parent(parent)
+	if err != nil {
+		return Commit{}, err
+	}
+	parentTree, err := p.Tree()
+	if err != nil {
+		return Commit{}, err
+	}
+	tree, err := obj.Tree()
+	if err != nil {
+		return Commit{}, err
+	}
+	c.Stats, c.Patch, c.Files, err = diff(parentTree, tree, p.Hash.String(), c.SHA)
+	return c, err
 }
 
 // LastCommit returns the last commit of the repo without any extra information
@@ -182,103 +262,196 @@ func (r Repo) LastCommit() (Commit, error) {
 		return Commit{}, err
 	}
 
-	return commit(repo, head.Hash().String(), false)
-}
-
-func commit(repo *git.Repository, sha string, extra bool) (Commit, error) {
-	obj, err := repo.CommitObject(plumbing.NewHash(sha))
+	obj, err := repo.CommitObject(head.Hash())
 	if err != nil {
 		return Commit{}, err
 	}
 
-	var c, a, d int
-	var p string
-	var f []CommitFile
-	if extra {
-		stats, err := obj.Stats()
+	return newCommit(obj), nil
+}
+
+// Size returns the size of the repo on disk in bytes
+func (r Repo) Size() (int64, error) {
+	var size int64
+	err := filepath.WalkDir(r.path, func(_ string, d fs.DirEntry, err error) error {
 		if err != nil {
-			return Commit{}, err
+			return err
 		}
-
-		c = len(stats)
-		for _, stat := range stats {
-			a += stat.Addition
-			d += stat.Deletion
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
 		}
+		return nil
+	})
+	return size, err
+}
+
+// newCommit returns a Commit without any extra information
+func newCommit(obj *object.Commit) Commit {
+	parents := make([]string, 0, len(obj.ParentHashes))
+	for _, parent := range obj.ParentHashes {
+		parents = append(parents, parent.String())
+	}
+	commit := Commit{
+		SHA:       obj.Hash.String(),
+		Parents:   parents,
+		Message:   obj.Message,
+		Signature: obj.PGPSignature,
+		Author:    obj.Author.Name,
+		Email:     obj.Author.Email,
+		When:      obj.Author.When,
+	}
+	if commit.Signature != "" {
+		commit.payload = signedPayload(obj)
+	}
+	return commit
+}
+
+// combinedDiff returns the files of a merge commit that differ from every parent,
+// each with its diff against every parent
+// Stats are relative to the first parent
+func combinedDiff(obj *object.Commit) (CommitStats, string, []CommitFile, error) {
+	tree, err := obj.Tree()
+	if err != nil {
+		return CommitStats{}, "", nil, err
+	}
 
-		parent, err := obj.Parent(0)
+	var parentTrees []*object.Tree
+	changed := make(map[string]int)
+	if err := obj.Parents().ForEach(func(parent *object.Commit) error {
+		parentTree, err := parent.Tree()
 		if err != nil {
-			return Commit{}, err
+			return err
 		}
+		parentTrees = append(parentTrees, parentTree)
 
-		patch, err := obj.Patch(parent)
+		changes, err := object.DiffTree(parentTree, tree)
 		if err != nil {
-			return Commit{}, err
+			return err
 		}
-
-		var buf bytes.Buffer
-		if err := patch.Encode(&buf); err != nil {
-			return Commit{}, err
+		for _, change := range changes {
+			name := change.To.Name
+			if name == "" {
+				name = change.From.Name
+			}
+			changed[name]++
 		}
-		p = buf.String()
+		return nil
+	}); err != nil {
+		return CommitStats{}, "", nil, err
+	}
+
+	keep := func(name string) bool {
+		return changed[name] == len(parentTrees)
+	}
 
-		objTree, err := obj.Tree()
+	var stats CommitStats
+	var patch strings.Builder
+	var files []CommitFile
+	patches := make(map[string]*strings.Builder)
+	for idx, parentTree := range parentTrees {
+		s, p, f, err := diffFiltered(parentTree, tree, obj.ParentHashes[idx].String(), obj.Hash.String(), keep)
 		if err != nil {
-			return Commit{}, err
+			return CommitStats{}, "", nil, err
 		}
-		parentTree, err := parent.Tree()
-		if err != nil {
-			return Commit{}, err
+		patch.WriteString(p)
+		if idx == 0 {
+			stats, files = s, f
+			for _, file := range files {
+				patches[file.Path()] = &strings.Builder{}
+			}
 		}
-
-		changes, err := parentTree.Diff(objTree)
-		if err != nil {
-			return Commit{}, err
+		for _, file := range f {
+			if b, ok := patches[file.Path()]; ok {
+				b.WriteString(file.Patch)
+			}
 		}
+	}
+	for idx, file := range files {
+		files[idx].Patch = patches[file.Path()].String()
+	}
 
-		for _, change := range changes {
-			action, err := change.Action()
-			if err != nil {
-				return Commit{}, err
-			}
-			patch, err := change.Patch()
-			if err != nil {
-				return Commit{}, err
+	return stats, patch.String(), files, nil
+}
+
+// diff returns the stats, full patch, and per-file changes between two trees
+// A nil from tree is treated as the empty tree
+func diff(from, to *object.Tree, fromSHA, toSHA string) (CommitStats, string, []CommitFile, error) {
+	return diffFiltered(from, to, fromSHA, toSHA, nil)
+}
+
+// diffFiltered is diff limited to the files where keep returns true, if keep is non-nil
+func diffFiltered(from, to *object.Tree, fromSHA, toSHA string, keep func(string) bool) (CommitStats, string, []CommitFile, error) {
+	all, err := object.DiffTree(from, to)
+	if err != nil {
+		return CommitStats{}, "", nil, err
+	}
+
+	changes := all
+	if keep != nil {
+		changes = nil
+		for _, change := range all {
+			name := change.To.Name
+			if name == "" {
+				name = change.From.Name
 			}
-			var buf bytes.Buffer
-			if err := patch.Encode(&buf); err != nil {
-				return Commit{}, err
+			if keep(name) {
+				changes = append(changes, change)
 			}
-			f = append(f, CommitFile{
-				From: CommitFileEntry{
-					Path:   change.From.Name,
-					Commit: parent.Hash.String(),
-				},
-				To: CommitFileEntry{
-					Path:   change.To.Name,
-					Commit: obj.Hash.String(),
-				},
-				Action: action.String(),
-				Patch:  buf.String(),
-			})
 		}
 	}
 
-	return Commit{
-		SHA:       obj.Hash.String(),
-		Message:   obj.Message,
-		Signature: obj.PGPSignature,
-		Author:    obj.Author.Name,
-		Email:     obj.Author.Email,
-		When:      obj.Author.When,
-		Stats: CommitStats{
-			Changed:   c,
-			Additions: a,
-			Deletions: d,
-		},
-		Patch: p,
-		Files: f,
-	}, nil
+	patch, err := changes.Patch()
+	if err != nil {
+		return CommitStats{}, "", nil, err
+	}
+
+	fileStats := patch.Stats()
+	stats := CommitStats{
+		Changed: len(fileStats),
+	}
+	for _, stat := range fileStats {
+		stats.Additions += stat.Addition
+		stats.Deletions += stat.Deletion
+	}
+
+	var full bytes.Buffer
+	if err := patch.Encode(&full); err != nil {
+		return CommitStats{}, "", nil, err
+	}
+
+	var files []CommitFile
+	for _, change := range changes {
+		action, err := change.Action()
+		if err != nil {
+			return CommitStats{}, "", nil, err
+		}
+		patch, err := change.Patch()
+		if err != nil {
+			return CommitStats{}, "", nil, err
+		}
+		var buf bytes.Buffer
+		if err := patch.Encode(&buf); err != nil {
+			return CommitStats{}, "", nil, err
+		}
+		files = append(files, CommitFile{
+			From: CommitFileEntry{
+				Path:   change.From.Name,
+				Commit: fromSHA,
+			},
+			To: CommitFileEntry{
+				Path:   change.To.Name,
+				Commit: toSHA,
+			},
+			Action: action.String(),
+			Patch:  buf.String(),
+		})
+	}
+
+	return stats, full.String(), files, nil
 }
 
 // Branches is all repo branches, default first and sorted alphabetically after that
@@ -315,10 +488,14 @@ func (r Repo) Branches() ([]string, error) {
 
 // Tag is a git tag, which may or may not have an annotation/signature
 type Tag struct {
-	Name       string
-	Annotation string
-	Signature  string
-	When       time.Time
+	Name         string
+	Annotation   string
+	Signature    string
+	When         time.Time
+	Verification Verification
+
+	// payload is what Signature signed
+	payload []byte
 }
 
 // Tags is all repo tags, sorted by time descending
@@ -342,19 +519,27 @@ func (r Repo) Tags() ([]Tag, error) {
 			if err != nil {
 				return err
 			}
-			tags = append(tags, Tag{
+			t := Tag{
 				Name:       tag.Name().Short(),
 				Annotation: commit.Message,
 				Signature:  commit.PGPSignature,
 				When:       commit.Author.When,
-			})
+			}
+			if t.Signature != "" {
+				t.payload = signedPayload(commit)
+			}
+			tags = append(tags, t)
 		case err == nil:
-			tags = append(tags, Tag{
+			t := Tag{
 				Name:       obj.Name,
 				Annotation: obj.Message,
 				Signature:  obj.PGPSignature,
 				When:       obj.Tagger.When,
-			})
+			}
+			if t.Signature != "" {
+				t.payload = signedPayload(obj)
+			}
+			tags = append(tags, t)
 		default:
 			return err
 		}
@@ -370,39 +555,122 @@ func (r Repo) Tags() ([]Tag, error) {
 	return tags, nil
 }
 
-// Commits returns commits from a specific hash in descending order
-func (r Repo) Commits(ref string) ([]Commit, error) {
+// LogOptions filter and paginate Commits
+type LogOptions struct {
+	// Path limits commits to those touching a file or directory
+	Path string
+	// Author limits commits to those whose author name or email contains Author, case-insensitive
+	Author string
+	// Since and Until limit commits to a committer date range
+	Since *time.Time
+	Until *time.Time
+	// After is a cursor, only commits following this SHA are returned
+	After string
+	// Limit is the maximum number of commits to return, or 0 for no limit
+	Limit int
+}
+
+// Commits returns commits from a specific hash in descending order, along with
+// the cursor to pass as LogOptions.After for the next page, empty if there are no more commits
+func (r Repo) Commits(ref string, opts LogOptions) ([]Commit, string, error) {
 	repo, err := r.Git()
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-
+	// Every page walks from the ref so commits from merged branches keep their place in the order,
+	// commits up to and including the cursor were on previous pages
 	cmts, err := repo.Log(&git.LogOptions{
-		From: *hash,
+		From:  *hash,
+		Since: opts.Since,
+		Until: opts.Until,
 	})
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	defer cmts.Close()
 
+	path := strings.Trim(opts.Path, "/")
+	author := strings.ToLower(opts.Author)
+	after := plumbing.NewHash(opts.After)
+	skip := opts.After != ""
 	var commits []Commit
+	var next string
 	if err := cmts.ForEach(func(commit *object.Commit) error {
-		commits = append(commits, Commit{
-			SHA:       commit.Hash.String(),
-			Message:   commit.Message,
-			Signature: commit.PGPSignature,
-			Author:    commit.Author.Name,
-			Email:     commit.Author.Email,
-			When:      commit.Author.When,
-		})
+		if skip {
+			skip = commit.Hash != after
+			return nil
+		}
+		if author != "" && !strings.Contains(strings.ToLower(commit.Author.Name), author) && !strings.Contains(strings.ToLower(commit.Author.Email), author) {
+			return nil
+		}
+		if path != "" {
+			ok, err := touchesPath(commit, path)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return nil
+			}
+		}
+		if opts.Limit > 0 && len(commits) == opts.Limit {
+			next = commits[len(commits)-1].SHA
+			return storer.ErrStop
+		}
+		commits = append(commits, newCommit(commit))
 		return nil
 	}); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	return commits, nil
+	return commits, next, nil
+}
+
+// touchesPath returns whether a commit changed path compared to every one of its parents
+// go-git's LogOptions.PathFilter compares against the previous commit in the walk rather than
+// the parents, which is incorrect for non-linear history
+func touchesPath(commit *object.Commit, path string) (bool, error) {
+	hash, err := pathHash(commit, path)
+	if err != nil {
+		return false, err
+	}
+	if commit.NumParents() == 0 {
+		return !hash.IsZero(), nil
+	}
+
+	touched := true
+	if err := commit.Parents().ForEach(func(parent *object.Commit) error {
+		parentHash, err := pathHash(parent, path)
+		if err != nil {
+			return err
+		}
+		if parentHash == hash {
+			touched = false
+			return storer.ErrStop
+		}
+		return nil
+	}); err != nil {
+		return false, err
+	}
+	return touched, nil
+}
+
+// pathHash returns the hash of the file or directory at path in a commit, or the zero hash if it doesn't exist
+func pathHash(commit *object.Commit, path string) (plumbing.Hash, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	entry, err := tree.FindEntry(path)
+	if err != nil {
+		if errors.Is(err, object.ErrEntryNotFound) || errors.Is(err, object.ErrDirectoryNotFound) || errors.Is(err, plumbing.ErrObjectNotFound) {
+			return plumbing.ZeroHash, nil
+		}
+		return plumbing.ZeroHash, err
+	}
+	return entry.Hash, nil
 }
@@ -0,0 +1,70 @@
+package git_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"go.jolheiser.com/ugit/internal/git"
+)
+
+func TestCommitsMergePages(t *testing.T) {
+	tmp := t.TempDir()
+	g, err := gogit.PlainInit(filepath.Join(tmp, "test.git"), false)
+	assert.NoError(t, err)
+	wt, err := g.Worktree()
+	assert.NoError(t, err)
+
+	// root - main1 - main2 - merge
+	//     \               /
+	//      side1 - side2
+	hashes := make(map[string]plumbing.Hash)
+	commit := func(name string, hour int, parents ...string) {
+		t.Helper()
+		opts := &gogit.CommitOptions{
+			AllowEmptyCommits: true,
+			Author:            &object.Signature{Name: "ugit", Email: "ugit@example.com", When: time.Date(2025, 1, 1, hour, 0, 0, 0, time.UTC)},
+		}
+		for _, parent := range parents {
+			opts.Parents = append(opts.Parents, hashes[parent])
+		}
+		hash, err := wt.Commit(name, opts)
+		assert.NoError(t, err)
+		hashes[name] = hash
+	}
+	commit("root", 0)
+	commit("main1", 1, "root")
+	commit("side1", 2, "root")
+	commit("main2", 3, "main1")
+	commit("side2", 4, "side1")
+	commit("merge", 5, "main2", "side2")
+	assert.NoError(t, g.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("master"), hashes["merge"])))
+
+	repo, err := git.NewRepo(tmp, "test")
+	assert.NoError(t, err)
+
+	all, next, err := repo.Commits("master", git.LogOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, len(hashes), len(all))
+	assert.Equal(t, "", next)
+
+	for limit := 1; limit <= len(hashes); limit++ {
+		var paged []git.Commit
+		var after string
+		for {
+			commits, next, err := repo.Commits("master", git.LogOptions{Limit: limit, After: after})
+			assert.NoError(t, err)
+			paged = append(paged, commits...)
+			if next == "" {
+				break
+			}
+			assert.Equal(t, commits[len(commits)-1].SHA, next)
+			after = next
+		}
+		assert.Equal(t, all, paged, "limit %d", limit)
+	}
+}
@@ -0,0 +1,301 @@
+package git
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	// searchIndexVersion is bumped whenever the on-disk format changes, invalidating existing indexes
+	searchIndexVersion = 1
+	// searchIndexMaxFileSize is the largest file that is indexed, larger files are always scanned
+	searchIndexMaxFileSize = 1 << 20
+)
+
+// searchIndex is a trigram index of the files at a commit, used to narrow which files a search needs to scan
+type searchIndex struct {
+	Version int
+	Commit  string
+	Files   []searchIndexFile
+}
+
+// searchIndexFile is an indexed file
+type searchIndexFile struct {
+	Path string
+	Blob string
+	// Trigrams are the sorted, unique trigrams of the lowercased file content
+	Trigrams []uint32
+	// Skipped files, such as binary or large files, weren't indexed and must always be scanned
+	Skipped bool
+}
+
+type cachedSearchIndex struct {
+	modTime time.Time
+	index   *searchIndex
+}
+
+// searchIndexCache holds the most recently loaded index for each repo, keyed by index path
+var searchIndexCache sync.Map
+
+// searchIndexLocks serializes updates to the index of each repo, keyed by index path
+var searchIndexLocks sync.Map
+
+func (r Repo) searchIndexPath() string {
+	return filepath.Join(r.path, "ugit-search.idx")
+}
+
+// UpdateSearchIndex indexes HEAD for searching, only reading files that changed since the last index
+func (r Repo) UpdateSearchIndex() error {
+	mu, _ := searchIndexLocks.LoadOrStore(r.searchIndexPath(), &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+
+	repo, err := r.Git()
+	if err != nil {
+		return err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			// Nothing to index in an empty repo
+			if err := os.Remove(r.searchIndexPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			return nil
+		}
+		return err
+	}
+
+	// Reuse trigrams of unchanged blobs from the previous index, if any
+	previous := make(map[string]searchIndexFile)
+	if old, err := r.loadSearchIndex(); err == nil {
+		if old.Commit == head.Hash().String() {
+			return nil
+		}
+		for _, file := range old.Files {
+			previous[file.Blob] = file
+		}
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+
+	index := searchIndex{
+		Version: searchIndexVersion,
+		Commit:  commit.Hash.String(),
+	}
+	if err := tree.Files().ForEach(func(file *object.File) error {
+		blob := file.Hash.String()
+		if prev, ok := previous[blob]; ok {
+			prev.Path = file.Name
+			index.Files = append(index.Files, prev)
+			return nil
+		}
+
+		indexed := searchIndexFile{
+			Path: file.Name,
+			Blob: blob,
+		}
+		if file.Size > searchIndexMaxFileSize {
+			indexed.Skipped = true
+			index.Files = append(index.Files, indexed)
+			return nil
+		}
+		content, err := blobContent(file)
+		if err != nil {
+			return err
+		}
+		if bytes.IndexByte(content, 0) >= 0 {
+			indexed.Skipped = true
+		} else {
+			indexed.Trigrams = trigrams(bytes.ToLower(content))
+		}
+		index.Files = append(index.Files, indexed)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return r.saveSearchIndex(index)
+}
+
+func blobContent(file *object.File) ([]byte, error) {
+	rc, err := file.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// saveSearchIndex atomically replaces the on-disk index
+func (r Repo) saveSearchIndex(index searchIndex) error {
+	fi, err := os.CreateTemp(r.path, "ugit-search-*.idx")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fi.Name())
+
+	if err := gob.NewEncoder(fi).Encode(index); err != nil {
+		fi.Close()
+		return err
+	}
+	if err := fi.Close(); err != nil {
+		return err
+	}
+	return os.Rename(fi.Name(), r.searchIndexPath())
+}
+
+// loadSearchIndex loads the on-disk index, reusing the in-memory copy if the file hasn't changed
+func (r Repo) loadSearchIndex() (*searchIndex, error) {
+	p := r.searchIndexPath()
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if cached, ok := searchIndexCache.Load(p); ok && cached.(cachedSearchIndex).modTime.Equal(info.ModTime()) {
+		return cached.(cachedSearchIndex).index, nil
+	}
+
+	fi, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+
+	var index searchIndex
+	if err := gob.NewDecoder(fi).Decode(&index); err != nil {
+		return nil, err
+	}
+	if index.Version != searchIndexVersion {
+		return nil, errors.New("search index version mismatch")
+	}
+	searchIndexCache.Store(p, cachedSearchIndex{modTime: info.ModTime(), index: &index})
+	return &index, nil
+}
+
+// searchCandidates returns a filter for the files at commit that may match re
+// ok is false when the index is missing or stale, in which case every file must be scanned
+func (r Repo) searchCandidates(commit string, re *regexp.Regexp) (keep func(string) bool, ok bool) {
+	index, err := r.loadSearchIndex()
+	if err != nil || index.Commit != commit {
+		return nil, false
+	}
+
+	required := requiredTrigrams(re)
+	if len(required) == 0 {
+		return func(string) bool { return true }, true
+	}
+
+	candidates := make(map[string]struct{})
+	for _, file := range index.Files {
+		if file.Skipped || containsAll(file.Trigrams, required) {
+			candidates[file.Path] = struct{}{}
+		}
+	}
+	return func(p string) bool {
+		_, ok := candidates[p]
+		return ok
+	}, true
+}
+
+func containsAll(sorted, required []uint32) bool {
+	for _, t := range required {
+		if _, found := slices.BinarySearch(sorted, t); !found {
+			return false
+		}
+	}
+	return true
+}
+
+func trigram(b []byte) uint32 {
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+}
+
+// trigrams returns the sorted, unique trigrams of content
+func trigrams(content []byte) []uint32 {
+	seen := make(map[uint32]struct{})
+	for i := 0; i+3 <= len(content); i++ {
+		seen[trigram(content[i:i+3])] = struct{}{}
+	}
+	ts := make([]uint32, 0, len(seen))
+	for t := range seen {
+		ts = append(ts, t)
+	}
+	slices.Sort(ts)
+	return ts
+}
+
+// requiredTrigrams returns the lowercased trigrams that any match of re must contain
+func requiredTrigrams(re *regexp.Regexp) []uint32 {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[uint32]struct{})
+	for _, literal := range requiredLiterals(parsed.Simplify()) {
+		lower := []byte(strings.ToLower(literal))
+		for i := 0; i+3 <= len(lower); i++ {
+			seen[trigram(lower[i:i+3])] = struct{}{}
+		}
+	}
+	ts := make([]uint32, 0, len(seen))
+	for t := range seen {
+		ts = append(ts, t)
+	}
+	return ts
+}
+
+// requiredLiterals returns literal strings that any match of re must contain
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var literals []string
+		var run strings.Builder
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral {
+				run.WriteString(string(sub.Rune))
+				continue
+			}
+			if run.Len() > 0 {
+				literals = append(literals, run.String())
+				run.Reset()
+			}
+			literals = append(literals, requiredLiterals(sub)...)
+		}
+		if run.Len() > 0 {
+			literals = append(literals, run.String())
+		}
+		return literals
+	}
+	return nil
+}
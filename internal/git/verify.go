@@ -0,0 +1,429 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/go-git/go-git/v5/plumbing"
+	"golang.org/x/crypto/ssh"
+)
+
+// SignatureStatus is the result of verifying the signature of a Commit or Tag
+type SignatureStatus int
+
+const (
+	// Unsigned has no signature to verify
+	Unsigned SignatureStatus = iota
+	// SignatureVerified is a valid signature made by a trusted key
+	SignatureVerified
+	// SignatureUnverified is a signature that is invalid, or made by a trusted key that isn't valid for it
+	SignatureUnverified
+	// SignatureUnknownKey is a signature made by a key that isn't trusted
+	SignatureUnknownKey
+)
+
+// String implements [fmt.Stringer]
+func (s SignatureStatus) String() string {
+	switch s {
+	case SignatureVerified:
+		return "Verified"
+	case SignatureUnverified:
+		return "Unverified"
+	case SignatureUnknownKey:
+		return "Unknown key"
+	default:
+		return ""
+	}
+}
+
+// Verification is the result of verifying a signature
+type Verification struct {
+	Status SignatureStatus
+	// Signer is who the key belongs to, e.g. an OpenPGP user ID or SSH principal, if the key is trusted
+	Signer string
+	// Key identifies the key the signature was made with, an OpenPGP key ID or SSH key fingerprint
+	Key string
+}
+
+// SigningKeys are the files keys trusted to sign commits and tags are read from
+type SigningKeys struct {
+	// GPGKeys are armored OpenPGP public keyrings
+	GPGKeys []string
+	// AllowedSigners is an SSH allowed signers file, the same format as git's gpg.ssh.allowedSignersFile
+	AllowedSigners string
+	// AuthorizedKeys are authorized_keys files whose keys are trusted signers, named by their comment
+	AuthorizedKeys []string
+}
+
+type signingKeysCtxKey struct{}
+
+// WithSigningKeys returns a context carrying the SigningKeys protected refs are checked against, for use by protocols
+func WithSigningKeys(ctx context.Context, keys SigningKeys) context.Context {
+	return context.WithValue(ctx, signingKeysCtxKey{}, keys)
+}
+
+// SigningKeysFromContext returns the SigningKeys stored by WithSigningKeys, if any
+func SigningKeysFromContext(ctx context.Context) SigningKeys {
+	keys, _ := ctx.Value(signingKeysCtxKey{}).(SigningKeys)
+	return keys
+}
+
+// Verifier verifies signatures against the keys loaded from SigningKeys
+// A nil Verifier trusts no keys
+type Verifier struct {
+	gpg openpgp.EntityList
+	ssh []sshSigner
+}
+
+// sshSigner is a trusted SSH signing key
+type sshSigner struct {
+	key  ssh.PublicKey
+	name string
+	// namespaces, if set, are the only namespaces the key can sign
+	namespaces []string
+	// validAfter and validBefore, if set, limit when the key can have signed, and the key is no longer trusted after validBefore
+	validAfter, validBefore time.Time
+}
+
+// Load reads the signing keys, files that don't exist are skipped so keys can be added without a restart
+func (k SigningKeys) Load() (*Verifier, error) {
+	var v Verifier
+	for _, path := range k.GPGKeys {
+		data, err := readKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 {
+			continue
+		}
+		entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("could not read OpenPGP keys from %q: %w", path, err)
+		}
+		v.gpg = append(v.gpg, entities...)
+	}
+
+	if k.AllowedSigners != "" {
+		data, err := readKeyFile(k.AllowedSigners)
+		if err != nil {
+			return nil, err
+		}
+		signers, err := parseAllowedSigners(data)
+		if err != nil {
+			return nil, fmt.Errorf("could not read allowed signers from %q: %w", k.AllowedSigners, err)
+		}
+		v.ssh = append(v.ssh, signers...)
+	}
+
+	for _, path := range k.AuthorizedKeys {
+		data, err := readKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for rest := data; len(bytes.TrimSpace(rest)) > 0; {
+			var key ssh.PublicKey
+			var comment string
+			key, comment, _, rest, err = ssh.ParseAuthorizedKey(rest)
+			if err != nil {
+				return nil, fmt.Errorf("could not read authorized keys from %q: %w", path, err)
+			}
+			if comment == "" {
+				comment = ssh.FingerprintSHA256(key)
+			}
+			v.ssh = append(v.ssh, sshSigner{key: key, name: comment})
+		}
+	}
+	return &v, nil
+}
+
+func readKeyFile(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return data, nil
+}
+
+// parseAllowedSigners parses lines of "principals [options] keytype key [comment]", see ssh-keygen(1)
+func parseAllowedSigners(data []byte) ([]sshSigner, error) {
+	var signers []sshSigner
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Principals are a comma-separated list that may be quoted
+		var principals string
+		if quoted, ok := strings.CutPrefix(line, `"`); ok {
+			principals, line, ok = strings.Cut(quoted, `"`)
+			if !ok {
+				return nil, fmt.Errorf("unterminated principals in %q", scanner.Text())
+			}
+		} else {
+			principals, line, _ = strings.Cut(line, " ")
+		}
+
+		// The rest is the same as authorized_keys, including the quoting of options
+		key, _, options, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("invalid key for %q: %w", principals, err)
+		}
+		signer := sshSigner{key: key, name: principals}
+		var skip bool
+		for _, option := range options {
+			name, value, _ := strings.Cut(option, "=")
+			value = strings.Trim(value, `"`)
+			switch strings.ToLower(name) {
+			case "cert-authority":
+				// Certificates aren't supported, so a CA can't vouch for any key
+				skip = true
+			case "namespaces":
+				signer.namespaces = strings.Split(value, ",")
+			case "valid-after":
+				signer.validAfter, err = parseSignerTime(value)
+			case "valid-before":
+				signer.validBefore, err = parseSignerTime(value)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s for %q: %w", name, principals, err)
+			}
+		}
+		if !skip {
+			signers = append(signers, signer)
+		}
+	}
+	return signers, scanner.Err()
+}
+
+// parseSignerTime parses the YYYYMMDD[HHMM[SS]][Z] times of allowed signers, which are local time unless suffixed with Z
+func parseSignerTime(value string) (time.Time, error) {
+	loc := time.Local
+	if trimmed, ok := strings.CutSuffix(value, "Z"); ok {
+		value, loc = trimmed, time.UTC
+	}
+	for _, layout := range []string{"20060102", "200601021504", "20060102150405"} {
+		if len(value) == len(layout) {
+			return time.ParseInLocation(layout, value, loc)
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// signedPayload returns the content a signature is made over, which is the object without its signature
+func signedPayload(obj interface {
+	EncodeWithoutSignature(plumbing.EncodedObject) error
+}) []byte {
+	encoded := &plumbing.MemoryObject{}
+	if err := obj.EncodeWithoutSignature(encoded); err != nil {
+		return nil
+	}
+	r, err := encoded.Reader()
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+	payload, err := io.ReadAll(r)
+	if err != nil {
+		return nil
+	}
+	return payload
+}
+
+// Verify verifies the signature of the Commit, setting its Verification
+func (c *Commit) Verify(v *Verifier) {
+	c.Verification = v.verify(c.Signature, c.payload, c.When)
+}
+
+// Verify verifies the signature of the Tag, setting its Verification
+func (t *Tag) Verify(v *Verifier) {
+	t.Verification = v.verify(t.Signature, t.payload, t.When)
+}
+
+// verify verifies a signature over payload, made at when
+func (v *Verifier) verify(signature string, payload []byte, when time.Time) Verification {
+	if v == nil {
+		v = &Verifier{}
+	}
+	signature = strings.TrimSpace(signature)
+	switch {
+	case signature == "":
+		return Verification{}
+	case payload == nil:
+		return Verification{Status: SignatureUnverified}
+	case strings.HasPrefix(signature, "-----BEGIN PGP SIGNATURE-----"):
+		return v.verifyGPG(signature, payload)
+	case strings.HasPrefix(signature, "-----BEGIN SSH SIGNATURE-----"):
+		return v.verifySSH(signature, payload, when)
+	default:
+		// Other formats, like X.509, can't be checked against any key
+		return Verification{Status: SignatureUnknownKey}
+	}
+}
+
+func (v *Verifier) verifyGPG(signature string, payload []byte) Verification {
+	block, err := armor.Decode(strings.NewReader(signature))
+	if err != nil {
+		return Verification{Status: SignatureUnverified}
+	}
+	p, err := packet.Read(block.Body)
+	if err != nil {
+		return Verification{Status: SignatureUnverified}
+	}
+	sig, ok := p.(*packet.Signature)
+	if !ok || sig.IssuerKeyId == nil {
+		return Verification{Status: SignatureUnverified}
+	}
+
+	verification := Verification{
+		Status: SignatureVerified,
+		Key:    fmt.Sprintf("%016X", *sig.IssuerKeyId),
+	}
+	if keys := v.gpg.KeysById(*sig.IssuerKeyId); len(keys) > 0 {
+		verification.Signer = primaryIdentity(keys[0].Entity)
+	}
+	// Keys are checked as of when the signature was made, so expiring a key doesn't invalidate what it signed
+	config := &packet.Config{Time: func() time.Time { return sig.CreationTime }}
+	signer, err := openpgp.CheckArmoredDetachedSignature(v.gpg, bytes.NewReader(payload), strings.NewReader(signature), config)
+	switch {
+	case errors.Is(err, pgperrors.ErrUnknownIssuer):
+		verification.Status = SignatureUnknownKey
+	// Revocation is checked as of now instead, the creation time is chosen by the signer so a revoked key could backdate it
+	case err != nil, gpgRevoked(v.gpg.KeysById(*sig.IssuerKeyId), signer, time.Now()):
+		verification.Status = SignatureUnverified
+	}
+	return verification
+}
+
+// gpgRevoked returns whether the key of signer, or its primary identity, is revoked
+func gpgRevoked(keys []openpgp.Key, signer *openpgp.Entity, now time.Time) bool {
+	for _, key := range keys {
+		if key.Entity != signer {
+			continue
+		}
+		_, identity := signer.PrimarySelfSignature()
+		if signer.Revoked(now) || key.Revoked(now) || (identity != nil && identity.Revoked(now)) {
+			return true
+		}
+	}
+	return false
+}
+
+func primaryIdentity(entity *openpgp.Entity) string {
+	if id := entity.PrimaryIdentity(); id != nil {
+		return id.Name
+	}
+	return ""
+}
+
+// sshSignature is the blob of an armored SSH signature, see PROTOCOL.sshsig in OpenSSH
+type sshSignature struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshSignatureMagic starts both the signature blob and the data that is signed
+const sshSignatureMagic = "SSHSIG"
+
+func (v *Verifier) verifySSH(signature string, payload []byte, when time.Time) Verification {
+	block, _ := pem.Decode([]byte(signature))
+	if block == nil || block.Type != "SSH SIGNATURE" {
+		return Verification{Status: SignatureUnverified}
+	}
+	blob, ok := bytes.CutPrefix(block.Bytes, []byte(sshSignatureMagic))
+	if !ok {
+		return Verification{Status: SignatureUnverified}
+	}
+	var sig sshSignature
+	if err := ssh.Unmarshal(blob, &sig); err != nil || sig.Version != 1 {
+		return Verification{Status: SignatureUnverified}
+	}
+	key, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return Verification{Status: SignatureUnverified}
+	}
+
+	verification := Verification{
+		Status: SignatureUnknownKey,
+		Key:    ssh.FingerprintSHA256(key),
+	}
+	idx := slices.IndexFunc(v.ssh, func(s sshSigner) bool {
+		return bytes.Equal(s.key.Marshal(), key.Marshal())
+	})
+	if idx < 0 {
+		return verification
+	}
+	signer := v.ssh[idx]
+	verification.Status, verification.Signer = SignatureUnverified, signer.name
+
+	switch {
+	case sig.Namespace != "git":
+		return verification
+	case len(signer.namespaces) > 0 && !slices.Contains(signer.namespaces, "git"):
+		return verification
+	case !signer.validAfter.IsZero() && when.Before(signer.validAfter):
+		return verification
+	case !signer.validBefore.IsZero() && when.After(signer.validBefore):
+		return verification
+	// Expiry is also checked as of now, the commit or tag time is chosen by the signer so an expired key could backdate it
+	case !signer.validBefore.IsZero() && time.Now().After(signer.validBefore):
+		return verification
+	}
+
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return verification
+	}
+	h.Write(payload)
+	signed := append([]byte(sshSignatureMagic), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{
+		Namespace:     sig.Namespace,
+		Reserved:      sig.Reserved,
+		HashAlgorithm: sig.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	})...)
+
+	var sshSig ssh.Signature
+	if err := ssh.Unmarshal(sig.Signature, &sshSig); err != nil {
+		return verification
+	}
+	if err := key.Verify(signed, &sshSig); err != nil {
+		return verification
+	}
+	verification.Status = SignatureVerified
+	return verification
+}
@@ -0,0 +1,428 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// Webhook is a URL that is sent a WebhookPayload after every push
+type Webhook struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+}
+
+var (
+	// WebhookAttempts is how many times a delivery is attempted before giving up
+	WebhookAttempts = 3
+	// WebhookBackoff is the wait before the first retry, doubling after each attempt
+	WebhookBackoff = time.Second
+	// WebhookTimeout is the timeout of a single delivery attempt
+	WebhookTimeout = 10 * time.Second
+	// WebhookMaxCommits is the most commits included per ref in a WebhookPayload
+	WebhookMaxCommits = 20
+	// WebhookLogLimit is how many deliveries the delivery log of a Repo keeps, older ones are dropped
+	WebhookLogLimit = 100
+)
+
+// Pusher is who pushed to a Repo
+type Pusher struct {
+	Name        string `json:"name,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Access is what the pusher is allowed to do to the Repo, which isn't part of a WebhookPayload
+	Access AccessLevel `json:"-"`
+}
+
+type pusherCtxKey struct{}
+
+// WithPusher returns a context carrying the Pusher, for use by protocols
+func WithPusher(ctx context.Context, pusher Pusher) context.Context {
+	return context.WithValue(ctx, pusherCtxKey{}, pusher)
+}
+
+// PusherFromContext returns the Pusher stored by WithPusher, if any
+func PusherFromContext(ctx context.Context) Pusher {
+	pusher, _ := ctx.Value(pusherCtxKey{}).(Pusher)
+	return pusher
+}
+
+// WebhookPayload is the JSON body sent to a Webhook
+type WebhookPayload struct {
+	Repo   string              `json:"repo"`
+	Pusher Pusher              `json:"pusher"`
+	Refs   []WebhookRefPayload `json:"refs"`
+}
+
+// WebhookRefPayload is a single updated ref in a WebhookPayload
+type WebhookRefPayload struct {
+	Ref     string                 `json:"ref"`
+	Before  string                 `json:"before"`
+	After   string                 `json:"after"`
+	Commits []WebhookCommitPayload `json:"commits"`
+}
+
+// WebhookCommitPayload is a commit summary in a WebhookPayload
+type WebhookCommitPayload struct {
+	SHA     string    `json:"sha"`
+	Summary string    `json:"summary"`
+	Author  string    `json:"author"`
+	Email   string    `json:"email"`
+	When    time.Time `json:"when"`
+}
+
+// WebhookDelivery is an entry in the webhook delivery log of a Repo
+type WebhookDelivery struct {
+	ID       string    `json:"id"`
+	URL      string    `json:"url"`
+	When     time.Time `json:"when"`
+	Attempts int       `json:"attempts"`
+	Status   int       `json:"status,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// WebhookSignature returns the value of the X-Ugit-Signature header for a body
+func WebhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (r Repo) webhookLogPath() string {
+	return filepath.Join(r.path, "ugit-webhooks.jsonl")
+}
+
+// webhookLogMu guards reading and writing the webhook delivery logs
+var webhookLogMu sync.Mutex
+
+// WebhookPayload builds the payload for a push, including a summary of new commits
+func (r Repo) WebhookPayload(pusher Pusher, refs []RefUpdate) (WebhookPayload, error) {
+	repo, err := r.Git()
+	if err != nil {
+		return WebhookPayload{}, err
+	}
+
+	payload := WebhookPayload{
+		Repo:   r.Name(),
+		Pusher: pusher,
+		Refs:   make([]WebhookRefPayload, 0, len(refs)),
+	}
+	for _, ref := range refs {
+		commits, err := newCommits(repo, ref)
+		if err != nil {
+			return WebhookPayload{}, err
+		}
+		payload.Refs = append(payload.Refs, WebhookRefPayload{
+			Ref:     ref.Name,
+			Before:  ref.Old,
+			After:   ref.New,
+			Commits: commits,
+		})
+	}
+	return payload, nil
+}
+
+// newCommits returns the commits reachable from the new hash that weren't from the old one, newest first
+func newCommits(repo *git.Repository, ref RefUpdate) ([]WebhookCommitPayload, error) {
+	commits := make([]WebhookCommitPayload, 0)
+	if plumbing.NewHash(ref.New).IsZero() {
+		return commits, nil
+	}
+
+	head, err := peelCommit(repo, plumbing.NewHash(ref.New))
+	if err != nil {
+		return nil, err
+	}
+	stop := plumbing.ZeroHash
+	if old := plumbing.NewHash(ref.Old); !old.IsZero() {
+		if c, err := peelCommit(repo, old); err == nil {
+			stop = c.Hash
+		}
+	}
+
+	iter, err := repo.Log(&git.LogOptions{From: head.Hash})
+	if err != nil {
+		return nil, err
+	}
+	if err := iter.ForEach(func(c *object.Commit) error {
+		if c.Hash == stop || len(commits) >= WebhookMaxCommits {
+			return storer.ErrStop
+		}
+		commits = append(commits, WebhookCommitPayload{
+			SHA:     c.Hash.String(),
+			Summary: Commit{Message: c.Message}.Summary(),
+			Author:  c.Author.Name,
+			Email:   c.Author.Email,
+			When:    c.Author.When,
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return commits, nil
+}
+
+// peelCommit resolves a commit or annotated tag hash to a commit
+func peelCommit(repo *git.Repository, hash plumbing.Hash) (*object.Commit, error) {
+	tag, err := repo.TagObject(hash)
+	if err == nil {
+		return tag.Commit()
+	}
+	if !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return nil, err
+	}
+	return repo.CommitObject(hash)
+}
+
+// DeliverWebhooks sends a WebhookPayload for the pushed refs to all of the Repo's webhooks,
+// retrying with backoff and recording every delivery in the Repo's delivery log
+func (r Repo) DeliverWebhooks(ctx context.Context, pusher Pusher, refs []RefUpdate) error {
+	if len(r.Meta.Webhooks) == 0 || len(refs) == 0 {
+		return nil
+	}
+
+	payload, err := r.WebhookPayload(pusher, refs)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	deliveries := make([]WebhookDelivery, len(r.Meta.Webhooks))
+	var wg sync.WaitGroup
+	for idx, hook := range r.Meta.Webhooks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			deliveries[idx] = deliverWebhook(ctx, hook, body)
+		}()
+	}
+	wg.Wait()
+
+	if err := r.logWebhookDeliveries(deliveries); err != nil {
+		return err
+	}
+	var errs []error
+	for _, delivery := range deliveries {
+		if delivery.Error != "" {
+			errs = append(errs, fmt.Errorf("could not deliver webhook to %s: %s", delivery.URL, delivery.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// logWebhookDeliveries adds deliveries to the delivery log, dropping the oldest entries past WebhookLogLimit
+func (r Repo) logWebhookDeliveries(deliveries []WebhookDelivery) error {
+	webhookLogMu.Lock()
+	defer webhookLogMu.Unlock()
+
+	logged, err := r.webhookDeliveries()
+	if err != nil {
+		return err
+	}
+	logged = append(logged, deliveries...)
+	if len(logged) > WebhookLogLimit {
+		logged = logged[len(logged)-WebhookLogLimit:]
+	}
+
+	fi, err := os.Create(r.webhookLogPath())
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+	enc := json.NewEncoder(fi)
+	for _, delivery := range logged {
+		if err := enc.Encode(delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deliverWebhook(ctx context.Context, hook Webhook, body []byte) WebhookDelivery {
+	delivery := WebhookDelivery{
+		ID:   deliveryID(),
+		URL:  hook.URL,
+		When: time.Now(),
+	}
+
+	backoff := WebhookBackoff
+	for delivery.Attempts < WebhookAttempts {
+		if delivery.Attempts > 0 {
+			select {
+			case <-ctx.Done():
+				delivery.Error = ctx.Err().Error()
+				return delivery
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		delivery.Attempts++
+
+		status, err := postWebhook(ctx, hook, delivery.ID, body)
+		delivery.Status = status
+		if err == nil {
+			delivery.Error = ""
+			return delivery
+		}
+		delivery.Error = err.Error()
+	}
+	return delivery
+}
+
+func postWebhook(ctx context.Context, hook Webhook, id string, body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, WebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ugit")
+	req.Header.Set("X-Ugit-Event", "push")
+	req.Header.Set("X-Ugit-Delivery", id)
+	if hook.Secret != "" {
+		req.Header.Set("X-Ugit-Signature", WebhookSignature(hook.Secret, body))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func deliveryID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WebhookDeliveries returns the webhook delivery log of a Repo, oldest first
+func (r Repo) WebhookDeliveries() ([]WebhookDelivery, error) {
+	webhookLogMu.Lock()
+	defer webhookLogMu.Unlock()
+	return r.webhookDeliveries()
+}
+
+func (r Repo) webhookDeliveries() ([]WebhookDelivery, error) {
+	fi, err := os.Open(r.webhookLogPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer fi.Close()
+
+	var deliveries []WebhookDelivery
+	dec := json.NewDecoder(fi)
+	for dec.More() {
+		var delivery WebhookDelivery
+		if err := dec.Decode(&delivery); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// WebhookQueue delivers webhooks in the background, so a push never waits on a slow or failing webhook
+type WebhookQueue struct {
+	mu   sync.Mutex
+	jobs []webhookJob
+	wake chan struct{}
+}
+
+type webhookJob struct {
+	dir    string
+	name   string
+	pusher Pusher
+	refs   []RefUpdate
+}
+
+// NewWebhookQueue returns a WebhookQueue
+func NewWebhookQueue() *WebhookQueue {
+	return &WebhookQueue{
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// Enqueue queues a delivery of the pushed refs to every Webhook of a Repo
+func (q *WebhookQueue) Enqueue(repo *Repo, pusher Pusher, refs []RefUpdate) {
+	if len(repo.Meta.Webhooks) == 0 || len(refs) == 0 {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs = append(q.jobs, webhookJob{dir: repo.dir, name: repo.name, pusher: pusher, refs: refs})
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *WebhookQueue) next() (webhookJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.jobs) == 0 {
+		return webhookJob{}, false
+	}
+	job := q.jobs[0]
+	q.jobs = q.jobs[1:]
+	return job, true
+}
+
+// Run delivers queued pushes one at a time, in the order they were pushed, until ctx is done
+// Failures are only logged, they may include webhook URLs that pushers shouldn't see
+func (q *WebhookQueue) Run(ctx context.Context) {
+	for {
+		job, ok := q.next()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.wake:
+			}
+			continue
+		}
+
+		// Reload the repo, the webhooks may have been changed or removed since the job was queued
+		repo, err := NewRepo(job.dir, job.name)
+		if err != nil {
+			slog.Error("could not open repo for webhooks", "repo", job.name, "error", err)
+			continue
+		}
+		if err := repo.DeliverWebhooks(ctx, job.pusher, job.refs); err != nil {
+			slog.Error("could not deliver webhooks", "repo", job.name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package git_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"go.jolheiser.com/ugit/internal/git"
+)
+
+func TestDeliverWebhooks(t *testing.T) {
+	backoff := git.WebhookBackoff
+	git.WebhookBackoff = time.Millisecond
+	t.Cleanup(func() { git.WebhookBackoff = backoff })
+
+	tmp := t.TempDir()
+	g, err := gogit.PlainInit(filepath.Join(tmp, "test.git"), false)
+	assert.NoError(t, err)
+	wt, err := g.Worktree()
+	assert.NoError(t, err)
+	sig := &object.Signature{Name: "ugit", Email: "ugit@example.com", When: time.Now()}
+	first, err := wt.Commit("first", &gogit.CommitOptions{AllowEmptyCommits: true, Author: sig})
+	assert.NoError(t, err)
+	second, err := wt.Commit("second\n\nwith details", &gogit.CommitOptions{AllowEmptyCommits: true, Author: sig})
+	assert.NoError(t, err)
+
+	var (
+		attempts  int
+		payload   git.WebhookPayload
+		signature string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(body, &payload))
+		signature = r.Header.Get("X-Ugit-Signature")
+		assert.Equal(t, git.WebhookSignature("s3cr3t", body), signature)
+	}))
+	defer srv.Close()
+
+	repo, err := git.NewRepo(tmp, "test")
+	assert.NoError(t, err)
+	repo.Meta.Webhooks = []git.Webhook{{URL: srv.URL, Secret: "s3cr3t"}}
+
+	pusher := git.Pusher{Name: "ugit", Fingerprint: "SHA256:abc"}
+	err = repo.DeliverWebhooks(t.Context(), pusher, []git.RefUpdate{
+		{Name: "refs/heads/main", Old: first.String(), New: second.String()},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, attempts)
+	assert.NotEqual(t, "", signature)
+	assert.Equal(t, "test", payload.Repo)
+	assert.Equal(t, pusher, payload.Pusher)
+	assert.Equal(t, 1, len(payload.Refs))
+	assert.Equal(t, second.String(), payload.Refs[0].After)
+	assert.Equal(t, 1, len(payload.Refs[0].Commits))
+	assert.Equal(t, "second", payload.Refs[0].Commits[0].Summary)
+
+	deliveries, err := repo.WebhookDeliveries()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(deliveries))
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusOK, deliveries[0].Status)
+	assert.Equal(t, "", deliveries[0].Error)
+}
+
+func TestWebhookQueue(t *testing.T) {
+	repo, hashes := newTestRepo(t, nil, nil)
+
+	received := make(chan git.WebhookPayload, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload git.WebhookPayload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received <- payload
+	}))
+	defer srv.Close()
+
+	repo.Meta.Webhooks = []git.Webhook{{URL: srv.URL}}
+	assert.NoError(t, repo.SaveMeta())
+
+	queue := git.NewWebhookQueue()
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go queue.Run(ctx)
+
+	pusher := git.Pusher{Name: "ugit"}
+	queue.Enqueue(repo, pusher, []git.RefUpdate{{Name: "refs/heads/main", Old: hashes[0], New: hashes[1]}})
+	queue.Enqueue(repo, pusher, []git.RefUpdate{{Name: "refs/heads/other", Old: hashes[0], New: hashes[1]}})
+
+	for _, ref := range []string{"refs/heads/main", "refs/heads/other"} {
+		select {
+		case payload := <-received:
+			assert.Equal(t, ref, payload.Refs[0].Ref)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("webhook for %s was not delivered", ref)
+		}
+	}
+
+	var deliveries []git.WebhookDelivery
+	for range 500 {
+		var err error
+		deliveries, err = repo.WebhookDeliveries()
+		assert.NoError(t, err)
+		if len(deliveries) == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 2, len(deliveries))
+}
+
+func TestWebhookLogLimit(t *testing.T) {
+	limit := git.WebhookLogLimit
+	git.WebhookLogLimit = 3
+	t.Cleanup(func() { git.WebhookLogLimit = limit })
+
+	repo, hashes := newTestRepo(t, nil, nil)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	repo.Meta.Webhooks = []git.Webhook{{URL: srv.URL + "/a"}, {URL: srv.URL + "/b"}}
+
+	refs := []git.RefUpdate{{Name: "refs/heads/main", Old: hashes[0], New: hashes[1]}}
+	assert.NoError(t, repo.DeliverWebhooks(t.Context(), git.Pusher{}, refs))
+	first, err := repo.WebhookDeliveries()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(first))
+
+	// Only the newest deliveries are kept
+	assert.NoError(t, repo.DeliverWebhooks(t.Context(), git.Pusher{}, refs))
+	deliveries, err := repo.WebhookDeliveries()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(deliveries))
+	assert.Equal(t, first[1], deliveries[0])
+	assert.Equal(t, srv.URL+"/a", deliveries[1].URL)
+	assert.Equal(t, srv.URL+"/b", deliveries[2].URL)
+}
@@ -3,8 +3,10 @@ package markup
 import (
 	"io"
 	"path/filepath"
+	"strings"
 
 	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
 	"github.com/alecthomas/chroma/v2/formatters/html"
 	"github.com/alecthomas/chroma/v2/lexers"
 	"github.com/alecthomas/chroma/v2/styles"
@@ -70,3 +72,55 @@ func Snippet(source []byte, fileName string, line int, writer io.Writer) error {
 	)
 	return formatter.Format(writer, style, iter)
 }
+
+// Hunks formats consecutive hunks of code with the given number of lines each, with line numbers
+// continuing between hunks. The source is highlighted as a whole so multi-line tokens are correct in every hunk.
+func Hunks(source []byte, fileName, linePrefix string, sizes []int) ([]string, error) {
+	iter, style, err := setup(source, fileName)
+	if err != nil {
+		return nil, err
+	}
+	lines := chroma.SplitTokensIntoLines(iter.Tokens())
+
+	hunks := make([]string, 0, len(sizes))
+	var start int
+	for _, size := range sizes {
+		end := min(start+size, len(lines))
+		var tokens []chroma.Token
+		for _, line := range lines[start:end] {
+			tokens = append(tokens, line...)
+		}
+		formatter := html.New(append(Options(linePrefix), html.BaseLineNumber(start+1))...)
+		var buf strings.Builder
+		if err := formatter.Format(&buf, style, chroma.Literator(tokens...)); err != nil {
+			return nil, err
+		}
+		hunks = append(hunks, buf.String())
+		start = end
+	}
+	return hunks, nil
+}
+
+// Terminal formats code for a 256-color terminal, returning each line separately and without its newline
+func Terminal(source []byte, fileName string) ([]string, error) {
+	iter, style, err := setup(source, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := chroma.SplitTokensIntoLines(iter.Tokens())
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		tokens := make([]chroma.Token, 0, len(line))
+		for _, token := range line {
+			token.Value = strings.TrimSuffix(token.Value, "\n")
+			tokens = append(tokens, token)
+		}
+		var buf strings.Builder
+		if err := formatters.TTY256.Format(&buf, style, chroma.Literator(tokens...)); err != nil {
+			return nil, err
+		}
+		out = append(out, buf.String())
+	}
+	return out, nil
+}
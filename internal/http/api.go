@@ -0,0 +1,238 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"go.jolheiser.com/ugit/api"
+	"go.jolheiser.com/ugit/internal/git"
+	"go.jolheiser.com/ugit/internal/http/httperr"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func writeJSON(w http.ResponseWriter, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return httperr.Error(err)
+	}
+	return nil
+}
+
+// apiStatus maps not-found errors from git to a 404
+func apiStatus(err error) error {
+	if errors.Is(err, plumbing.ErrReferenceNotFound) ||
+		errors.Is(err, plumbing.ErrObjectNotFound) ||
+		errors.Is(err, object.ErrFileNotFound) ||
+		errors.Is(err, object.ErrDirectoryNotFound) {
+		return httperr.Status(err, http.StatusNotFound)
+	}
+	return httperr.Error(err)
+}
+
+func apiRepo(repo *git.Repo) api.Repo {
+	defaultBranch, _ := repo.DefaultBranch()
+	tags := repo.Meta.Tags.Slice()
+	if tags == nil {
+		tags = []string{}
+	}
+	resp := api.Repo{
+		Name:          repo.Name(),
+		Description:   repo.Meta.Description,
+		Private:       repo.Private(),
+		Tags:          tags,
+		DefaultBranch: defaultBranch,
+	}
+	if repo.Meta.Mirror != nil {
+		resp.Mirror = repo.Meta.Mirror.DisplayURL()
+	}
+	return resp
+}
+
+func apiCommit(commit git.Commit) api.Commit {
+	return api.Commit{
+		SHA:       commit.SHA,
+		Parents:   commit.Parents,
+		Message:   commit.Message,
+		Signature: commit.Signature,
+		Author:    commit.Author,
+		Email:     commit.Email,
+		When:      commit.When,
+	}
+}
+
+func (rh repoHandler) apiRepos(w http.ResponseWriter, r *http.Request) error {
+	repos, err := rh.visibleRepos(r)
+	if err != nil {
+		return httperr.Error(err)
+	}
+
+	tagFilter := strings.ToLower(r.URL.Query().Get("tag"))
+	resp := make([]api.Repo, 0, len(repos))
+	for _, entry := range repos {
+		if tagFilter != "" && !entry.Repo.Meta.Tags.Contains(tagFilter) {
+			continue
+		}
+		resp = append(resp, apiRepo(entry.Repo))
+	}
+	return writeJSON(w, resp)
+}
+
+func (rh repoHandler) apiRepo(w http.ResponseWriter, r *http.Request) error {
+	repo := r.Context().Value(repoCtxKey).(*git.Repo)
+	return writeJSON(w, apiRepo(repo))
+}
+
+func (rh repoHandler) apiBranches(w http.ResponseWriter, r *http.Request) error {
+	repo := r.Context().Value(repoCtxKey).(*git.Repo)
+
+	branches, err := repo.Branches()
+	if err != nil {
+		return httperr.Error(err)
+	}
+	if branches == nil {
+		branches = []string{}
+	}
+	return writeJSON(w, branches)
+}
+
+func (rh repoHandler) apiTags(w http.ResponseWriter, r *http.Request) error {
+	repo := r.Context().Value(repoCtxKey).(*git.Repo)
+
+	tags, err := repo.Tags()
+	if err != nil {
+		return httperr.Error(err)
+	}
+
+	resp := make([]api.Tag, 0, len(tags))
+	for _, tag := range tags {
+		resp = append(resp, api.Tag{
+			Name:       tag.Name,
+			Annotation: tag.Annotation,
+			Signature:  tag.Signature,
+			When:       tag.When,
+		})
+	}
+	return writeJSON(w, resp)
+}
+
+func (rh repoHandler) apiTree(w http.ResponseWriter, r *http.Request) error {
+	repo := r.Context().Value(repoCtxKey).(*git.Repo)
+	ref, path := chi.URLParam(r, "ref"), strings.Trim(chi.URLParam(r, "*"), "/")
+
+	tree, err := repo.Dir(ref, path)
+	if err != nil {
+		return apiStatus(err)
+	}
+
+	resp := make([]api.TreeEntry, 0, len(tree))
+	for _, fi := range tree {
+		resp = append(resp, api.TreeEntry{
+			Path:  fi.Path,
+			IsDir: fi.IsDir,
+			Mode:  fi.Mode,
+			Size:  fi.Size,
+		})
+	}
+	return writeJSON(w, resp)
+}
+
+func (rh repoHandler) apiFile(w http.ResponseWriter, r *http.Request) error {
+	repo := r.Context().Value(repoCtxKey).(*git.Repo)
+	ref, path := chi.URLParam(r, "ref"), chi.URLParam(r, "*")
+
+	content, err := repo.FileContent(ref, path)
+	if err != nil {
+		return apiStatus(err)
+	}
+
+	return writeJSON(w, api.File{
+		Path:    path,
+		Ref:     ref,
+		Content: []byte(content),
+	})
+}
+
+func (rh repoHandler) apiCommits(w http.ResponseWriter, r *http.Request) error {
+	repo := r.Context().Value(repoCtxKey).(*git.Repo)
+
+	opts, err := logOptions(r.URL.Query())
+	if err != nil {
+		return err
+	}
+	opts.Path = r.URL.Query().Get("path")
+
+	commits, next, err := repo.Commits(chi.URLParam(r, "ref"), opts)
+	if err != nil {
+		return apiStatus(err)
+	}
+
+	resp := api.CommitPage{
+		Commits: make([]api.Commit, 0, len(commits)),
+		Next:    next,
+	}
+	for _, commit := range commits {
+		resp.Commits = append(resp.Commits, apiCommit(commit))
+	}
+	return writeJSON(w, resp)
+}
+
+func (rh repoHandler) apiCommit(w http.ResponseWriter, r *http.Request) error {
+	repo := r.Context().Value(repoCtxKey).(*git.Repo)
+
+	commit, err := repo.Commit(chi.URLParam(r, "commit"))
+	if err != nil {
+		return apiStatus(err)
+	}
+
+	resp := apiCommit(commit)
+	resp.Stats = &api.CommitStats{
+		Changed:   commit.Stats.Changed,
+		Additions: commit.Stats.Additions,
+		Deletions: commit.Stats.Deletions,
+	}
+	resp.Files = make([]api.CommitFile, 0, len(commit.Files))
+	for _, file := range commit.Files {
+		resp.Files = append(resp.Files, api.CommitFile{
+			From:   file.From.Path,
+			To:     file.To.Path,
+			Action: file.Action,
+			Patch:  file.Patch,
+		})
+	}
+	return writeJSON(w, resp)
+}
+
+func (rh repoHandler) apiSearch(w http.ResponseWriter, r *http.Request) error {
+	repo := r.Context().Value(repoCtxKey).(*git.Repo)
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		return httperr.Status(errors.New("missing q"), http.StatusBadRequest)
+	}
+
+	opts, err := grepOptions(r.URL.Query())
+	if err != nil {
+		return err
+	}
+
+	results, _, err := repo.Grep(q, opts)
+	if err != nil {
+		return searchStatus(err)
+	}
+
+	resp := make([]api.GrepResult, 0, len(results))
+	for _, result := range results {
+		resp = append(resp, api.GrepResult{
+			File:      result.File,
+			StartLine: result.StartLine,
+			Line:      result.Line,
+			Content:   result.Content,
+		})
+	}
+	return writeJSON(w, resp)
+}
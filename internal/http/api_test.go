@@ -0,0 +1,209 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"go.jolheiser.com/ugit/api"
+	"go.jolheiser.com/ugit/internal/git"
+)
+
+func TestAPI(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is required")
+	}
+	tmp := t.TempDir()
+	repoDir := filepath.Join(tmp, "repos")
+	assert.NoError(t, os.MkdirAll(repoDir, os.ModePerm))
+
+	// Both repos get the same history, the private one is only visible to "reader"
+	clientDir := filepath.Join(tmp, "client")
+	assert.NoError(t, os.MkdirAll(filepath.Join(clientDir, "docs"), os.ModePerm))
+	assert.NoError(t, os.WriteFile(filepath.Join(clientDir, "README.md"), []byte("# ugit\n"), 0o644))
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=ugit", "-c", "user.email=ugit@example.com"}, args...)...)
+		cmd.Dir = clientDir
+		out, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	run("init", "-q", "-b", "main")
+	run("add", ".")
+	run("commit", "-q", "-m", "first")
+	first := run("rev-parse", "HEAD")
+	assert.NoError(t, os.WriteFile(filepath.Join(clientDir, "docs", "guide.md"), []byte("needle\n"), 0o644))
+	run("add", ".")
+	run("commit", "-q", "-m", "second")
+	second := run("rev-parse", "HEAD")
+	run("tag", "-a", "v1", "-m", "version 1")
+
+	for _, name := range []string{"public", "group/secret"} {
+		repo, err := git.CreateRepo(repoDir, name)
+		assert.NoError(t, err)
+		repo.Meta.Private = name != "public"
+		repo.Meta.Readers = []string{"reader"}
+		repo.Meta.Tags.Add("go")
+		assert.NoError(t, repo.SaveMeta())
+		run("push", "-q", repo.Path(), "main", "v1")
+	}
+
+	srv, _ := newTestServer(t, Settings{RepoDir: repoDir})
+	ctx := context.Background()
+	anon := api.New(srv.URL)
+	reader := api.New(srv.URL, api.WithToken("reader", "reader-token"))
+
+	t.Run("repos", func(t *testing.T) {
+		repos, err := anon.Repos(ctx)
+		assert.NoError(t, err)
+		names := make([]string, 0, len(repos))
+		for _, repo := range repos {
+			names = append(names, repo.Name)
+		}
+		slices.Sort(names)
+		assert.Equal(t, []string{"public", "test"}, names)
+
+		repos, err = reader.Repos(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(repos))
+		for _, repo := range repos {
+			if repo.Name == "group/secret" {
+				assert.True(t, repo.Private)
+				assert.Equal(t, []string{"go"}, repo.Tags)
+			}
+		}
+	})
+
+	t.Run("repo", func(t *testing.T) {
+		repo, err := reader.Repo(ctx, "group/secret")
+		assert.NoError(t, err)
+		assert.Equal(t, api.Repo{Name: "group/secret", Private: true, Tags: []string{"go"}, DefaultBranch: "main"}, repo)
+
+		repo, err = anon.Repo(ctx, "test")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{}, repo.Tags)
+	})
+
+	t.Run("private", func(t *testing.T) {
+		_, err := anon.Repo(ctx, "group/secret")
+		assertStatus(t, http.StatusNotFound, err)
+		_, err = anon.Commits(ctx, "group/secret", "main", api.CommitOptions{})
+		assertStatus(t, http.StatusNotFound, err)
+		_, err = api.New(srv.URL, api.WithToken("writer", "writer-token")).Repo(ctx, "group/secret")
+		assertStatus(t, http.StatusNotFound, err)
+		_, err = api.New(srv.URL, api.WithToken("reader", "wrong")).Repo(ctx, "group/secret")
+		assertStatus(t, http.StatusNotFound, err)
+
+		// Errors from before a handler is reached are JSON too
+		for _, path := range []string{"/api/v1/repos/group/secret", "/api/v1/repos/missing", "/api/v1/repos/group/missing/tags"} {
+			resp, err := http.Get(srv.URL + path)
+			assert.NoError(t, err)
+			var body api.Error
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body), path)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+			assert.Equal(t, "application/json", resp.Header.Get("Content-Type"), path)
+			assert.Equal(t, http.StatusText(http.StatusNotFound), body.Message, path)
+		}
+	})
+
+	t.Run("refs", func(t *testing.T) {
+		branches, err := anon.Branches(ctx, "public")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"main"}, branches)
+
+		tags, err := reader.Tags(ctx, "group/secret")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(tags))
+		assert.Equal(t, "v1", tags[0].Name)
+		assert.Equal(t, "version 1", strings.TrimSpace(tags[0].Annotation))
+	})
+
+	t.Run("tree", func(t *testing.T) {
+		entries, err := anon.Tree(ctx, "public", "main", "")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(entries))
+		assert.Equal(t, "docs", entries[0].Path)
+		assert.True(t, entries[0].IsDir)
+		assert.Equal(t, "README.md", entries[1].Path)
+
+		entries, err = anon.Tree(ctx, "public", first, "")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(entries))
+
+		_, err = anon.Tree(ctx, "public", "main", "missing")
+		assertStatus(t, http.StatusNotFound, err)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		file, err := reader.File(ctx, "group/secret", "main", "docs/guide.md")
+		assert.NoError(t, err)
+		assert.Equal(t, api.File{Path: "docs/guide.md", Ref: "main", Content: []byte("needle\n")}, file)
+
+		_, err = anon.File(ctx, "public", "missing", "README.md")
+		assertStatus(t, http.StatusNotFound, err)
+	})
+
+	t.Run("commits", func(t *testing.T) {
+		page, err := anon.Commits(ctx, "public", "main", api.CommitOptions{Limit: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(page.Commits))
+		assert.Equal(t, second, page.Commits[0].SHA)
+		assert.Equal(t, []string{first}, page.Commits[0].Parents)
+		assert.NotZero(t, page.Next)
+
+		page, err = anon.Commits(ctx, "public", "main", api.CommitOptions{Limit: 1, After: page.Next})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(page.Commits))
+		assert.Equal(t, first, page.Commits[0].SHA)
+		assert.Zero(t, page.Next)
+
+		page, err = anon.Commits(ctx, "public", "main", api.CommitOptions{Path: "docs"})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(page.Commits))
+		assert.Equal(t, second, page.Commits[0].SHA)
+
+		_, err = anon.Commits(ctx, "public", "main", api.CommitOptions{After: "not-a-cursor"})
+		assertStatus(t, http.StatusBadRequest, err)
+	})
+
+	t.Run("commit", func(t *testing.T) {
+		commit, err := anon.Commit(ctx, "public", second)
+		assert.NoError(t, err)
+		assert.Equal(t, "second", strings.TrimSpace(commit.Message))
+		assert.Equal(t, "ugit", commit.Author)
+		assert.Equal(t, &api.CommitStats{Changed: 1, Additions: 1}, commit.Stats)
+		assert.Equal(t, 1, len(commit.Files))
+		assert.Equal(t, "docs/guide.md", commit.Files[0].To)
+
+		_, err = anon.Commit(ctx, "public", strings.Repeat("0", 40))
+		assertStatus(t, http.StatusNotFound, err)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		results, err := anon.Search(ctx, "public", "needle")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(results))
+		assert.Equal(t, "docs/guide.md", results[0].File)
+
+		_, err = anon.Search(ctx, "public", " ")
+		assertStatus(t, http.StatusBadRequest, err)
+	})
+}
+
+func assertStatus(t *testing.T, status int, err error) {
+	t.Helper()
+	var statusErr api.StatusError
+	assert.True(t, errors.As(err, &statusErr), "expected a StatusError, got %v", err)
+	assert.Equal(t, status, statusErr.StatusCode)
+	assert.Equal(t, http.StatusText(status), statusErr.Message)
+}
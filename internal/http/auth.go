@@ -0,0 +1,200 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.jolheiser.com/ugit/internal/git"
+	"go.jolheiser.com/ugit/internal/html"
+	"go.jolheiser.com/ugit/internal/http/httperr"
+)
+
+// AccessToken is a token a user can authenticate with via HTTP basic auth or the login page
+type AccessToken struct {
+	User  string
+	Token string
+}
+
+const sessionCookie = "ugit_session"
+
+// SessionDuration is how long a login lasts
+var SessionDuration = 30 * 24 * time.Hour
+
+func randomSecret() []byte {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return b
+}
+
+// checkToken returns whether the token belongs to the user
+func (rh repoHandler) checkToken(user, token string) bool {
+	for _, t := range rh.s.AccessTokens {
+		if t.User == user && subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// user returns the name of the user authenticated via HTTP basic auth or a session cookie, if any
+func (rh repoHandler) user(r *http.Request) (string, bool) {
+	if username, password, ok := r.BasicAuth(); ok {
+		if !rh.checkToken(username, password) {
+			return "", false
+		}
+		return username, true
+	}
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", false
+	}
+	return rh.verifySession(cookie.Value)
+}
+
+func (rh repoHandler) sign(payload string) string {
+	mac := hmac.New(sha256.New, rh.s.SessionSecret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// tokenHash identifies the token a session was created with, without revealing it
+func (rh repoHandler) tokenHash(token string) string {
+	return rh.sign("token|" + token)
+}
+
+// newSession returns a signed session value in the form of user|token-hash|expiry|signature
+func (rh repoHandler) newSession(user, token string, expiry time.Time) string {
+	payload := fmt.Sprintf("%s|%s|%d", base64.RawURLEncoding.EncodeToString([]byte(user)), rh.tokenHash(token), expiry.Unix())
+	return payload + "|" + rh.sign(payload)
+}
+
+func (rh repoHandler) verifySession(value string) (string, bool) {
+	idx := strings.LastIndex(value, "|")
+	if idx == -1 {
+		return "", false
+	}
+	payload, signature := value[:idx], value[idx+1:]
+	if !hmac.Equal([]byte(signature), []byte(rh.sign(payload))) {
+		return "", false
+	}
+	parts := strings.Split(payload, "|")
+	if len(parts) != 3 {
+		return "", false
+	}
+	encodedUser, tokenHash, expiryStr := parts[0], parts[1], parts[2]
+	expiry, err := strconv.ParseInt(expiryStr, 10, 64)
+	if err != nil || time.Now().After(time.Unix(expiry, 0)) {
+		return "", false
+	}
+	user, err := base64.RawURLEncoding.DecodeString(encodedUser)
+	if err != nil {
+		return "", false
+	}
+	// The token may have been revoked or changed since the session was created
+	if !slices.ContainsFunc(rh.s.AccessTokens, func(t AccessToken) bool {
+		return t.User == string(user) && hmac.Equal([]byte(tokenHash), []byte(rh.tokenHash(t.Token)))
+	}) {
+		return "", false
+	}
+	return string(user), true
+}
+
+// access returns the AccessLevel of the request to a given repo
+func (rh repoHandler) access(r *http.Request, repo *git.Repo) git.AccessLevel {
+	// Tokens from git-lfs-authenticate are only good for LFS requests to the repo they were issued for
+	if token, ok := lfsToken(r); ok && rh.s.LFS != nil && isLFSRequest(r) {
+		return max(rh.s.LFS.Verify(token, repo.Name()), repo.Access())
+	}
+	user, ok := rh.user(r)
+	if !ok {
+		return repo.Access()
+	}
+	if slices.Contains(rh.s.Admins, user) {
+		return git.AdminAccess
+	}
+	return repo.Access(user)
+}
+
+// canView returns whether the request may see a repo at all
+func (rh repoHandler) canView(r *http.Request, repo *git.Repo) bool {
+	return rh.s.ShowPrivate || rh.access(r, repo) >= git.ReadOnlyAccess
+}
+
+// requireAuth asks the client for credentials
+func requireAuth(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="ugit", charset="UTF-8"`)
+}
+
+func (rh repoHandler) loginPage(w http.ResponseWriter, r *http.Request) error {
+	if err := html.Login(html.LoginContext{
+		BaseContext: rh.baseContext(r),
+	}).Render(r.Context(), w); err != nil {
+		return httperr.Error(err)
+	}
+	return nil
+}
+
+func (rh repoHandler) login(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return httperr.Status(err, http.StatusBadRequest)
+	}
+	user, token := r.PostForm.Get("username"), r.PostForm.Get("token")
+	if !rh.checkToken(user, token) {
+		w.WriteHeader(http.StatusUnauthorized)
+		if err := html.Login(html.LoginContext{
+			BaseContext: rh.baseContext(r),
+			Error:       "invalid username or token",
+		}).Render(r.Context(), w); err != nil {
+			return httperr.Error(err)
+		}
+		return nil
+	}
+
+	expiry := time.Now().Add(SessionDuration)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    rh.newSession(user, token, expiry),
+		Path:     "/",
+		Expires:  expiry,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || strings.HasPrefix(rh.s.CloneURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return nil
+}
+
+func (rh repoHandler) logout(w http.ResponseWriter, r *http.Request) error {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return nil
+}
+
+var errNoLogin = errors.New("login is not enabled")
+
+// loginEnabled guards the login routes when there are no access tokens configured
+func (rh repoHandler) loginEnabled(next http.Handler) http.Handler {
+	return httperr.Handler(func(w http.ResponseWriter, r *http.Request) error {
+		if len(rh.s.AccessTokens) == 0 {
+			return httperr.Status(errNoLogin, http.StatusNotFound)
+		}
+		next.ServeHTTP(w, r)
+		return nil
+	})
+}
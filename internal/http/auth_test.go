@@ -0,0 +1,61 @@
+package http
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestSession(t *testing.T) {
+	rh := repoHandler{s: Settings{
+		AccessTokens:  []AccessToken{{User: "jolheiser", Token: "token"}},
+		SessionSecret: []byte("secret"),
+	}}
+
+	session := rh.newSession("jolheiser", "token", time.Now().Add(time.Hour))
+	user, ok := rh.verifySession(session)
+	assert.True(t, ok)
+	assert.Equal(t, "jolheiser", user)
+
+	_, ok = rh.verifySession(session + "x")
+	assert.False(t, ok, "tampered session should be invalid")
+
+	_, ok = rh.verifySession(rh.newSession("jolheiser", "token", time.Now().Add(-time.Hour)))
+	assert.False(t, ok, "expired session should be invalid")
+
+	_, ok = rh.verifySession(rh.newSession("revoked", "token", time.Now().Add(time.Hour)))
+	assert.False(t, ok, "session for a user without a token should be invalid")
+
+	_, ok = rh.verifySession(rh.newSession("jolheiser", "old", time.Now().Add(time.Hour)))
+	assert.False(t, ok, "session created with a token that was since changed should be invalid")
+
+	other := repoHandler{s: Settings{
+		AccessTokens:  rh.s.AccessTokens,
+		SessionSecret: []byte("other"),
+	}}
+	_, ok = other.verifySession(session)
+	assert.False(t, ok, "session signed with another secret should be invalid")
+}
+
+func TestUser(t *testing.T) {
+	rh := repoHandler{s: Settings{
+		AccessTokens:  []AccessToken{{User: "jolheiser", Token: "token"}},
+		SessionSecret: []byte("secret"),
+	}}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	_, ok := rh.user(req)
+	assert.False(t, ok)
+
+	req.SetBasicAuth("jolheiser", "wrong")
+	user, ok := rh.user(req)
+	assert.False(t, ok)
+	assert.Equal(t, "", user)
+
+	req.SetBasicAuth("jolheiser", "token")
+	user, ok = rh.user(req)
+	assert.True(t, ok)
+	assert.Equal(t, "jolheiser", user)
+}
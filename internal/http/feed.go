@@ -0,0 +1,324 @@
+package http
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.jolheiser.com/ugit/internal/git"
+	"go.jolheiser.com/ugit/internal/html"
+	"go.jolheiser.com/ugit/internal/http/httperr"
+)
+
+// feedFormats maps supported feed suffixes to their content type
+var feedFormats = map[string]string{
+	".atom": "application/atom+xml",
+	".rss":  "application/rss+xml",
+}
+
+// parseFeed splits a path such as "main.atom" into its name and feed suffix
+func parseFeed(p string) (string, string, bool) {
+	for suffix := range feedFormats {
+		if name, ok := strings.CutSuffix(p, suffix); ok && name != "" {
+			return name, suffix, true
+		}
+	}
+	return "", "", false
+}
+
+// isFeedRequest returns whether a request is for a feed
+func isFeedRequest(r *http.Request) bool {
+	_, _, ok := parseFeed(r.URL.Path)
+	return ok
+}
+
+// feedLinks returns the alternate links for a feed path without a suffix, e.g. "/index"
+func feedLinks(title, p string) []html.FeedLink {
+	return []html.FeedLink{
+		{Title: title, URL: p + ".atom", Type: feedFormats[".atom"]},
+		{Title: title, URL: p + ".rss", Type: feedFormats[".rss"]},
+	}
+}
+
+type feed struct {
+	Title       string
+	Description string
+	Link        string
+	Self        string
+	Author      string
+	Updated     time.Time
+	Entries     []feedEntry
+}
+
+type feedEntry struct {
+	Title   string
+	Link    string
+	Author  string
+	Email   string
+	Content string
+	Updated time.Time
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Content atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Author      string  `xml:"author,omitempty"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	ID          string `xml:",chardata"`
+}
+
+func (f feed) atom() atomFeed {
+	af := atomFeed{
+		Title:   f.Title,
+		ID:      f.Self,
+		Updated: f.Updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.Link, Rel: "alternate"},
+			{Href: f.Self, Rel: "self"},
+		},
+		Author: &atomAuthor{Name: f.Author},
+	}
+	for _, entry := range f.Entries {
+		ae := atomEntry{
+			Title:   entry.Title,
+			ID:      entry.Link,
+			Updated: entry.Updated.Format(time.RFC3339),
+			Link:    atomLink{Href: entry.Link, Rel: "alternate"},
+			Content: atomContent{Type: "text", Body: entry.Content},
+		}
+		if entry.Author != "" {
+			ae.Author = &atomAuthor{Name: entry.Author, Email: entry.Email}
+		}
+		af.Entries = append(af.Entries, ae)
+	}
+	return af
+}
+
+func (f feed) rss() rssFeed {
+	rf := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Description,
+			LastBuildDate: f.Updated.Format(time.RFC1123Z),
+		},
+	}
+	for _, entry := range f.Entries {
+		item := rssItem{
+			Title:       entry.Title,
+			Link:        entry.Link,
+			GUID:        rssGUID{IsPermaLink: true, ID: entry.Link},
+			PubDate:     entry.Updated.Format(time.RFC1123Z),
+			Description: entry.Content,
+		}
+		if entry.Email != "" {
+			item.Author = fmt.Sprintf("%s (%s)", entry.Email, entry.Author)
+		}
+		rf.Channel.Items = append(rf.Channel.Items, item)
+	}
+	return rf
+}
+
+// writeFeed writes a feed in the format given by its suffix
+func writeFeed(w http.ResponseWriter, f feed, suffix string) error {
+	if f.Updated.IsZero() {
+		for _, entry := range f.Entries {
+			if entry.Updated.After(f.Updated) {
+				f.Updated = entry.Updated
+			}
+		}
+		if f.Updated.IsZero() {
+			f.Updated = time.Now()
+		}
+	}
+
+	var v any = f.atom()
+	if suffix == ".rss" {
+		v = f.rss()
+	}
+
+	w.Header().Set("Content-Type", feedFormats[suffix]+"; charset=utf-8")
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(v)
+}
+
+// baseURL returns the absolute URL of the server, preferring the configured clone URL
+func (rh repoHandler) baseURL(r *http.Request) string {
+	if rh.s.CloneURL != "" {
+		return strings.TrimSuffix(rh.s.CloneURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, r.Host)
+}
+
+func (rh repoHandler) feedAuthor() string {
+	if rh.s.Profile.Username != "" {
+		return rh.s.Profile.Username
+	}
+	return rh.s.Title
+}
+
+func (rh repoHandler) indexFeed(w http.ResponseWriter, r *http.Request) error {
+	_, suffix, _ := parseFeed(r.URL.Path)
+
+	repos, err := rh.repos(r, "")
+	if err != nil {
+		return httperr.Error(err)
+	}
+
+	base := rh.baseURL(r)
+	f := feed{
+		Title:       rh.s.Title,
+		Description: rh.s.Description,
+		Link:        base + "/",
+		Self:        base + r.URL.Path,
+		Author:      rh.feedAuthor(),
+	}
+	for _, entry := range repos {
+		commit := entry.LastCommit
+		if commit == nil {
+			continue
+		}
+		content := fmt.Sprintf("%s\n\n%s %s", entry.Repo.Meta.Description, commit.Short(), commit.Summary())
+		f.Entries = append(f.Entries, feedEntry{
+			Title:   entry.Repo.Name(),
+			Link:    fmt.Sprintf("%s/%s", base, entry.Repo.Name()),
+			Content: strings.TrimSpace(content),
+			Updated: commit.When,
+		})
+	}
+
+	if err := writeFeed(w, f, suffix); err != nil {
+		return httperr.Error(err)
+	}
+	return nil
+}
+
+func (rh repoHandler) repoLogFeed(w http.ResponseWriter, r *http.Request, ref, suffix string) error {
+	repo := r.Context().Value(repoCtxKey).(*git.Repo)
+
+	commits, _, err := repo.Commits(ref, git.LogOptions{Limit: defaultLogLimit})
+	if err != nil {
+		return httperr.Error(err)
+	}
+
+	base := rh.baseURL(r)
+	f := feed{
+		Title:       fmt.Sprintf("%s commits on %s", repo.Name(), ref),
+		Description: repo.Meta.Description,
+		Link:        fmt.Sprintf("%s/%s/log/%s", base, repo.Name(), ref),
+		Self:        base + r.URL.Path,
+		Author:      rh.feedAuthor(),
+	}
+	for _, commit := range commits {
+		f.Entries = append(f.Entries, feedEntry{
+			Title:   commit.Summary(),
+			Link:    fmt.Sprintf("%s/%s/commit/%s", base, repo.Name(), commit.SHA),
+			Author:  commit.Author,
+			Email:   commit.Email,
+			Content: commit.Message,
+			Updated: commit.When,
+		})
+	}
+
+	if err := writeFeed(w, f, suffix); err != nil {
+		return httperr.Error(err)
+	}
+	return nil
+}
+
+func (rh repoHandler) repoRefsFeed(w http.ResponseWriter, r *http.Request) error {
+	repo := r.Context().Value(repoCtxKey).(*git.Repo)
+	_, suffix, _ := parseFeed(r.URL.Path)
+
+	tags, err := repo.Tags()
+	if err != nil {
+		return httperr.Error(err)
+	}
+
+	base := rh.baseURL(r)
+	f := feed{
+		Title:       fmt.Sprintf("%s tags", repo.Name()),
+		Description: repo.Meta.Description,
+		Link:        fmt.Sprintf("%s/%s/refs", base, repo.Name()),
+		Self:        base + r.URL.Path,
+		Author:      rh.feedAuthor(),
+	}
+	for _, tag := range tags {
+		f.Entries = append(f.Entries, feedEntry{
+			Title:   tag.Name,
+			Link:    fmt.Sprintf("%s/%s/tree/%s/", base, repo.Name(), tag.Name),
+			Content: tag.Annotation,
+			Updated: tag.When,
+		})
+	}
+
+	if err := writeFeed(w, f, suffix); err != nil {
+		return httperr.Error(err)
+	}
+	return nil
+}
@@ -9,20 +9,27 @@ import (
 )
 
 func (rh repoHandler) infoRefs(w http.ResponseWriter, r *http.Request) error {
-	if r.URL.Query().Get("service") != "git-upload-pack" {
-		return httperr.Status(errors.New("pushing isn't supported via HTTP(S), use SSH"), http.StatusBadRequest)
+	service := r.URL.Query().Get("service")
+	switch service {
+	case "git-upload-pack":
+	case "git-receive-pack":
+		if err := rh.authorizePush(w, r); err != nil {
+			return err
+		}
+	default:
+		return httperr.Status(errors.New("only smart HTTP is supported"), http.StatusBadRequest)
 	}
 
-	w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+	w.Header().Set("Content-Type", "application/x-"+service+"-advertisement")
 	repo := r.Context().Value(repoCtxKey).(*git.Repo)
-	protocol, err := git.NewProtocol(repo.Path())
+	protocol, err := git.NewProtocol(rh.s.RepoDir, repo.Name())
 	if err != nil {
 		return httperr.Error(err)
 	}
 	if err := protocol.HTTPInfoRefs(Session{
 		w: w,
 		r: r,
-	}); err != nil {
+	}, service); err != nil {
 		return httperr.Error(err)
 	}
 
@@ -32,7 +39,7 @@ func (rh repoHandler) infoRefs(w http.ResponseWriter, r *http.Request) error {
 func (rh repoHandler) uploadPack(w http.ResponseWriter, r *http.Request) error {
 	w.Header().Set("content-type", "application/x-git-upload-pack-result")
 	repo := r.Context().Value(repoCtxKey).(*git.Repo)
-	protocol, err := git.NewProtocol(repo.Path())
+	protocol, err := git.NewProtocol(rh.s.RepoDir, repo.Name())
 	if err != nil {
 		return httperr.Error(err)
 	}
@@ -45,3 +52,63 @@ func (rh repoHandler) uploadPack(w http.ResponseWriter, r *http.Request) error {
 
 	return nil
 }
+
+func (rh repoHandler) receivePack(w http.ResponseWriter, r *http.Request) error {
+	if err := rh.authorizePush(w, r); err != nil {
+		return err
+	}
+
+	w.Header().Set("content-type", "application/x-git-receive-pack-result")
+	// Re-open the repo, the middleware may have modified its meta for display purposes
+	ctxRepo := r.Context().Value(repoCtxKey).(*git.Repo)
+	repo, err := git.NewRepo(rh.s.RepoDir, ctxRepo.Name())
+	if err != nil {
+		return httperr.Error(err)
+	}
+	protocol, err := git.NewProtocol(rh.s.RepoDir, repo.Name())
+	if err != nil {
+		return httperr.Error(err)
+	}
+	user, _ := rh.user(r)
+	pusher := git.Pusher{Name: user, Access: rh.access(r, repo)}
+	r = r.WithContext(git.WithSigningKeys(git.WithPusher(r.Context(), pusher), rh.s.Signing))
+	before, err := repo.Refs()
+	if err != nil {
+		return httperr.Error(err)
+	}
+	if err := protocol.HTTPReceivePack(Session{
+		w: w,
+		r: r,
+	}, repo); err != nil {
+		return httperr.Error(err)
+	}
+
+	if err := repo.AfterReceive(); err != nil {
+		return httperr.Error(err)
+	}
+	after, err := repo.Refs()
+	if err != nil {
+		return httperr.Error(err)
+	}
+	git.PostReceive{
+		Catalog:     rh.s.Catalog,
+		PushMirrors: rh.s.PushMirrors,
+		Webhooks:    rh.s.Webhooks,
+		Commands:    rh.s.PushHooks,
+	}.Run(repo, pusher, git.DiffRefs(before, after))
+
+	return nil
+}
+
+// authorizePush ensures the request is authenticated and can write to the repo
+func (rh repoHandler) authorizePush(w http.ResponseWriter, r *http.Request) error {
+	repo := r.Context().Value(repoCtxKey).(*git.Repo)
+	if _, ok := rh.user(r); !ok {
+		requireAuth(w)
+		return httperr.Status(errors.New("authentication required to push"), http.StatusUnauthorized)
+	}
+	if rh.access(r, repo) < git.ReadWriteAccess {
+		return httperr.Status(errors.New("not authorized to push"), http.StatusForbidden)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"go.jolheiser.com/ugit/internal/git"
+)
+
+// newTestServer serves a repo dir with a public repo "test" that "writer" can push to and "reader" can't
+func newTestServer(t *testing.T, settings Settings) (*httptest.Server, *git.Repo) {
+	t.Helper()
+	if settings.RepoDir == "" {
+		settings.RepoDir = t.TempDir()
+	}
+	settings.AccessTokens = []AccessToken{
+		{User: "writer", Token: "writer-token"},
+		{User: "reader", Token: "reader-token"},
+		{User: "admin", Token: "admin-token"},
+	}
+	settings.Admins = []string{"admin"}
+	repo, err := git.CreateRepo(settings.RepoDir, "test")
+	assert.NoError(t, err)
+	repo.Meta.Private = false
+	repo.Meta.Writers = []string{"writer"}
+	repo.Meta.Readers = []string{"reader"}
+	assert.NoError(t, repo.SaveMeta())
+
+	srv := httptest.NewServer(New(settings).Mux)
+	t.Cleanup(srv.Close)
+	return srv, repo
+}
+
+func TestAuthorizePush(t *testing.T) {
+	srv, _ := newTestServer(t, Settings{})
+
+	tt := []struct {
+		name   string
+		user   string
+		token  string
+		status int
+	}{
+		{name: "anonymous", status: http.StatusUnauthorized},
+		{name: "wrong token", user: "writer", token: "reader-token", status: http.StatusUnauthorized},
+		{name: "unknown user", user: "nobody", token: "writer-token", status: http.StatusUnauthorized},
+		{name: "reader", user: "reader", token: "reader-token", status: http.StatusForbidden},
+		{name: "writer", user: "writer", token: "writer-token", status: http.StatusOK},
+		{name: "admin", user: "admin", token: "admin-token", status: http.StatusOK},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, path := range []string{"/test.git/info/refs?service=git-receive-pack", "/test.git/git-receive-pack"} {
+				method := http.MethodGet
+				if strings.HasSuffix(path, "/git-receive-pack") {
+					method = http.MethodPost
+				}
+				req, err := http.NewRequest(method, srv.URL+path, nil)
+				assert.NoError(t, err)
+				if tc.user != "" {
+					req.SetBasicAuth(tc.user, tc.token)
+				}
+				resp, err := http.DefaultClient.Do(req)
+				assert.NoError(t, err)
+				resp.Body.Close()
+				if method == http.MethodPost && tc.status == http.StatusOK {
+					// An empty request is authorized, but isn't a valid push
+					assert.NotEqual(t, http.StatusUnauthorized, resp.StatusCode, path)
+					assert.NotEqual(t, http.StatusForbidden, resp.StatusCode, path)
+					continue
+				}
+				assert.Equal(t, tc.status, resp.StatusCode, path)
+				if tc.status == http.StatusUnauthorized {
+					assert.NotZero(t, resp.Header.Get("WWW-Authenticate"), path)
+				}
+			}
+		})
+	}
+}
+
+func TestReceivePack(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is required")
+	}
+	tmp := t.TempDir()
+	hookOut := filepath.Join(tmp, "hook.out")
+	// The output path is passed as an argument so it doesn't need quoting, and the file is renamed into place once written
+	hook := `sh -c 'cat > "$1.tmp" && echo "$UGIT_REPO $UGIT_USER" >> "$1.tmp" && mv "$1.tmp" "$1"' _ ` + hookOut
+	srv, repo := newTestServer(t, Settings{RepoDir: filepath.Join(tmp, "repos"), PushHooks: []string{hook}})
+
+	clientDir := filepath.Join(tmp, "client")
+	run := func(args ...string) (string, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = clientDir
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+	assert.NoError(t, os.MkdirAll(clientDir, os.ModePerm))
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=ugit", "-c", "user.email=ugit@example.com", "commit", "-q", "--allow-empty", "-m", "commit"},
+	} {
+		out, err := run(args...)
+		assert.NoError(t, err, out)
+	}
+	remote := func(user, token string) string {
+		return strings.Replace(srv.URL, "http://", "http://"+user+":"+token+"@", 1) + "/test.git"
+	}
+
+	out, err := run("push", remote("reader", "reader-token"), "main")
+	assert.Error(t, err, out)
+	out, err = run("push", remote("writer", "wrong"), "main")
+	assert.Error(t, err, out)
+
+	out, err = run("push", remote("writer", "writer-token"), "main")
+	assert.NoError(t, err, out)
+	head, err := run("rev-parse", "HEAD")
+	assert.NoError(t, err)
+	commit, err := repo.LastCommit()
+	assert.NoError(t, err)
+	assert.Equal(t, strings.TrimSpace(head), commit.SHA)
+
+	// Push hooks run in the background
+	var stdin []byte
+	for range 500 {
+		if stdin, err = os.ReadFile(hookOut); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NoError(t, err)
+	assert.Equal(t, "0000000000000000000000000000000000000000 "+strings.TrimSpace(head)+" refs/heads/main\ntest writer\n", string(stdin))
+}
@@ -28,13 +28,29 @@ func (s Server) ListenAndServe() error {
 
 // Settings is the configuration for the HTTP server
 type Settings struct {
-	Title       string
-	Description string
-	CloneURL    string
-	Port        int
-	RepoDir     string
-	Profile     Profile
-	ShowPrivate bool
+	Title        string
+	Description  string
+	CloneURL     string
+	Port         int
+	RepoDir      string
+	Profile      Profile
+	ShowPrivate  bool
+	AccessTokens []AccessToken
+	Admins       []string
+	// SessionSecret signs login sessions, a random secret is used if empty
+	SessionSecret []byte
+	// Catalog caches the repo listing, one is created for RepoDir if nil
+	Catalog *git.Catalog
+	// PushMirrors queues pushes to push mirrors, if set
+	PushMirrors *git.PushMirrorQueue
+	// Webhooks queues webhook deliveries, if set
+	Webhooks *git.WebhookQueue
+	// PushHooks are commands run after every push, the same as for pushes over SSH
+	PushHooks []string
+	// LFS verifies tokens handed out by the SSH server for LFS transfers, if set
+	LFS *git.LFSAuth
+	// Signing are the keys commit and tag signatures are verified against
+	Signing git.SigningKeys
 }
 
 // Profile is the index profile
@@ -62,37 +78,84 @@ func New(settings Settings) Server {
 	mux.Use(middleware.Logger)
 	mux.Use(middleware.Recoverer)
 
+	if len(settings.SessionSecret) == 0 {
+		settings.SessionSecret = randomSecret()
+	}
+	if settings.Catalog == nil {
+		settings.Catalog = git.NewCatalog(settings.RepoDir)
+	}
 	rh := repoHandler{s: settings}
-	mux.Route("/", func(r chi.Router) {
-		r.Get("/", httperr.Handler(rh.index))
-		r.Route("/{repo}", func(r chi.Router) {
-			r.Use(rh.repoMiddleware)
-			r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+
+	repoRoutes := chi.NewRouter()
+	repoRoutes.Use(rh.repoMiddleware)
+	// The go tool asks for the import path, which may be a package within the repo
+	repoRoutes.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Has("go-get") {
 				repo := r.Context().Value(repoCtxKey).(*git.Repo)
-				if r.URL.Query().Has("go-get") {
-					w.Write([]byte(settings.goGet(repo.Name())))
-					return
-				}
-				if strings.HasSuffix(chi.URLParam(r, "repo"), ".git") {
-					http.Redirect(w, r, "/"+repo.Name(), http.StatusFound)
-					return
-				}
-				rh.repoTree("", "").ServeHTTP(w, r)
-			})
-			r.Get("/tree/{ref}/*", func(w http.ResponseWriter, r *http.Request) {
-				rh.repoTree(chi.URLParam(r, "ref"), chi.URLParam(r, "*")).ServeHTTP(w, r)
-			})
-			r.Get("/refs", httperr.Handler(rh.repoRefs))
-			r.Get("/log/{ref}", httperr.Handler(rh.repoLog))
-			r.Get("/commit/{commit}", httperr.Handler(rh.repoCommit))
-			r.Get("/commit/{commit}.patch", httperr.Handler(rh.repoPatch))
-			r.Get("/search", httperr.Handler(rh.repoSearch))
-
-			// Protocol
-			r.Get("/info/refs", httperr.Handler(rh.infoRefs))
-			r.Post("/git-upload-pack", httperr.Handler(rh.uploadPack))
+				w.Write([]byte(settings.goGet(repo.Name())))
+				return
+			}
+			next.ServeHTTP(w, r)
 		})
 	})
+	repoRoutes.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		repo := r.Context().Value(repoCtxKey).(*git.Repo)
+		if strings.HasSuffix(chi.URLParam(r, "repo"), ".git") {
+			http.Redirect(w, r, "/"+repo.Name(), http.StatusFound)
+			return
+		}
+		rh.repoTree("", "").ServeHTTP(w, r)
+	})
+	repoRoutes.Get("/tree/{ref}/*", func(w http.ResponseWriter, r *http.Request) {
+		rh.repoTree(chi.URLParam(r, "ref"), chi.URLParam(r, "*")).ServeHTTP(w, r)
+	})
+	repoRoutes.Get("/blame/{ref}/*", httperr.Handler(rh.repoBlame))
+	repoRoutes.Get("/refs", httperr.Handler(rh.repoRefs))
+	repoRoutes.Get("/refs.atom", httperr.Handler(rh.repoRefsFeed))
+	repoRoutes.Get("/refs.rss", httperr.Handler(rh.repoRefsFeed))
+	repoRoutes.Get("/archive/*", httperr.Handler(rh.repoArchive))
+	repoRoutes.Get("/log/{ref}", httperr.Handler(rh.repoLog))
+	repoRoutes.Get("/log/{ref}/*", httperr.Handler(rh.repoLog))
+	repoRoutes.Get("/commit/{commit}", httperr.Handler(rh.repoCommit))
+	repoRoutes.Get("/commit/{commit}.patch", httperr.Handler(rh.repoPatch))
+	repoRoutes.Get("/compare/*", httperr.Handler(rh.repoCompare))
+	repoRoutes.Get("/search", httperr.Handler(rh.repoSearch))
+
+	// Protocol
+	repoRoutes.Get("/info/refs", httperr.Handler(rh.infoRefs))
+	repoRoutes.Post("/git-upload-pack", httperr.Handler(rh.uploadPack))
+	repoRoutes.Post("/git-receive-pack", httperr.Handler(rh.receivePack))
+
+	// Git LFS
+	repoRoutes.Post("/info/lfs/objects/batch", httperr.LFSHandler(rh.lfsBatch))
+	repoRoutes.Get("/info/lfs/objects/{oid}", httperr.LFSHandler(rh.lfsDownload))
+	repoRoutes.Put("/info/lfs/objects/{oid}", httperr.LFSHandler(rh.lfsUpload))
+
+	apiRepoRoutes := chi.NewRouter()
+	apiRepoRoutes.Use(rh.apiRepoMiddleware)
+	apiRepoRoutes.Get("/", httperr.JSONHandler(rh.apiRepo))
+	apiRepoRoutes.Get("/branches", httperr.JSONHandler(rh.apiBranches))
+	apiRepoRoutes.Get("/tags", httperr.JSONHandler(rh.apiTags))
+	apiRepoRoutes.Get("/tree/{ref}", httperr.JSONHandler(rh.apiTree))
+	apiRepoRoutes.Get("/tree/{ref}/*", httperr.JSONHandler(rh.apiTree))
+	apiRepoRoutes.Get("/file/{ref}/*", httperr.JSONHandler(rh.apiFile))
+	apiRepoRoutes.Get("/commits/{ref}", httperr.JSONHandler(rh.apiCommits))
+	apiRepoRoutes.Get("/commit/{commit}", httperr.JSONHandler(rh.apiCommit))
+	apiRepoRoutes.Get("/search", httperr.JSONHandler(rh.apiSearch))
+
+	mux.Route("/", func(r chi.Router) {
+		r.Get("/", httperr.Handler(rh.index))
+		r.Get("/index.atom", httperr.Handler(rh.indexFeed))
+		r.Get("/index.rss", httperr.Handler(rh.indexFeed))
+		// Repos may be nested in namespaces, e.g. /group/repo/tree/main
+		r.Handle("/*", httperr.Handler(rh.namespaceRouter(repoRoutes, rh.namespace)))
+	})
+
+	mux.Route("/api/v1", func(r chi.Router) {
+		r.Get("/repos", httperr.JSONHandler(rh.apiRepos))
+		r.Handle("/repos/*", httperr.JSONHandler(rh.namespaceRouter(apiRepoRoutes, nil)))
+	})
 
 	mux.Route("/_", func(r chi.Router) {
 		r.Get("/favicon.svg", func(w http.ResponseWriter, r *http.Request) {
@@ -100,6 +163,13 @@ func New(settings Settings) Server {
 			w.Write(assets.LogoIcon)
 		})
 		r.Get("/tailwind.css", html.TailwindHandler)
+		r.Get("/search", httperr.Handler(rh.search))
+		r.Group(func(r chi.Router) {
+			r.Use(rh.loginEnabled)
+			r.Get("/login", httperr.Handler(rh.loginPage))
+			r.Post("/login", httperr.Handler(rh.login))
+			r.Get("/logout", httperr.Handler(rh.logout))
+		})
 	})
 
 	return Server{Mux: mux, port: settings.Port}
@@ -109,18 +179,33 @@ type repoHandler struct {
 	s Settings
 }
 
-func (rh repoHandler) baseContext() html.BaseContext {
+func (rh repoHandler) baseContext(r *http.Request) html.BaseContext {
+	user, _ := rh.user(r)
 	return html.BaseContext{
 		Title:       rh.s.Title,
 		Description: rh.s.Description,
+		User:        user,
+		CanLogin:    len(rh.s.AccessTokens) > 0,
+		Feeds:       feedLinks(rh.s.Title, "/index"),
 	}
 }
 
-func (rh repoHandler) repoBaseContext(repo *git.Repo) html.BaseContext {
-	return html.BaseContext{
-		Title:       repo.Name(),
-		Description: repo.Meta.Description,
+func (rh repoHandler) repoBaseContext(repo *git.Repo, r *http.Request) html.BaseContext {
+	bc := rh.baseContext(r)
+	bc.Title = repo.Name()
+	bc.Description = repo.Meta.Description
+	ref := chi.URLParam(r, "ref")
+	if ref == "" {
+		ref, _ = repo.DefaultBranch()
+	}
+	if name, _, ok := parseFeed(ref); ok {
+		ref = name
 	}
+	bc.Feeds = append(
+		feedLinks(fmt.Sprintf("%s commits on %s", repo.Name(), ref), fmt.Sprintf("/%s/log/%s", repo.Name(), ref)),
+		feedLinks(fmt.Sprintf("%s tags", repo.Name()), fmt.Sprintf("/%s/refs", repo.Name()))...,
+	)
+	return bc
 }
 
 func (rh repoHandler) repoHeaderContext(repo *git.Repo, r *http.Request) html.RepoHeaderComponentContext {
@@ -128,13 +213,27 @@ func (rh repoHandler) repoHeaderContext(repo *git.Repo, r *http.Request) html.Re
 	if ref == "" {
 		ref, _ = repo.DefaultBranch()
 	}
-	return html.RepoHeaderComponentContext{
+	rhcc := html.RepoHeaderComponentContext{
 		Description: repo.Meta.Description,
-		Name:        chi.URLParam(r, "repo"),
+		Name:        repo.Name(),
 		Ref:         ref,
 		CloneURL:    rh.s.CloneURL,
 		Tags:        repo.Meta.Tags.Slice(),
+		Mirror:      repo.Meta.Mirror,
+	}
+	if repo.Meta.Mirror != nil {
+		rhcc.MirrorStatus, _ = repo.MirrorStatus()
+	}
+	if len(repo.Meta.PushMirrors) > 0 {
+		statuses, _ := repo.PushMirrorStatuses()
+		for _, mirror := range repo.Meta.PushMirrors {
+			rhcc.PushMirrors = append(rhcc.PushMirrors, html.RepoPushMirror{
+				PushMirror: mirror,
+				Status:     statuses[mirror.URL],
+			})
+		}
 	}
+	return rhcc
 }
 
 func (rh repoHandler) repoBreadcrumbContext(repo *git.Repo, r *http.Request, path string) html.RepoBreadcrumbComponentContext {
@@ -143,7 +242,7 @@ func (rh repoHandler) repoBreadcrumbContext(repo *git.Repo, r *http.Request, pat
 		ref, _ = repo.DefaultBranch()
 	}
 	return html.RepoBreadcrumbComponentContext{
-		Repo: chi.URLParam(r, "repo"),
+		Repo: repo.Name(),
 		Ref:  ref,
 		Path: path,
 	}
@@ -1,6 +1,7 @@
 package httperr
 
 import (
+	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
@@ -33,15 +34,49 @@ func Status(err error, status int) httpError {
 func Handler(fn func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := fn(w, r); err != nil {
-			status := http.StatusInternalServerError
-
-			var httpErr httpError
-			if errors.As(err, &httpErr) {
-				status = httpErr.status
-			}
-
+			status := statusOf(err)
 			slog.Error("httperr Handler error", "error", err)
 			http.Error(w, http.StatusText(status), status)
 		}
 	}
 }
+
+// JSONHandler is like Handler, but responds to errors with a JSON body of {"error": "<status text>"}
+func JSONHandler(fn func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := fn(w, r); err != nil {
+			status := statusOf(err)
+			slog.Error("httperr JSONHandler error", "error", err)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": http.StatusText(status)})
+		}
+	}
+}
+
+// LFSHandler is like Handler, but responds to errors as the Git LFS API expects, with a body of {"message": "<error>"}
+// Unlike other handlers the error itself is sent, as git-lfs shows it to the user
+func LFSHandler(fn func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := fn(w, r); err != nil {
+			status := statusOf(err)
+			slog.Error("httperr LFSHandler error", "error", err)
+			message := err.Error()
+			if status == http.StatusInternalServerError {
+				message = http.StatusText(status)
+			}
+			w.Header().Set("Content-Type", "application/vnd.git-lfs+json")
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(map[string]string{"message": message})
+		}
+	}
+}
+
+func statusOf(err error) int {
+	var httpErr httpError
+	if errors.As(err, &httpErr) {
+		return httpErr.status
+	}
+	return http.StatusInternalServerError
+}
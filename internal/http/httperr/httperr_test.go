@@ -120,3 +120,35 @@ func TestStatus(t *testing.T) {
 
 	assert.Equal(t, http.StatusNotFound, recorder.Code)
 }
+
+func TestJSONHandler(t *testing.T) {
+	handler := httperr.JSONHandler(statusErrorHandler(http.StatusNotFound))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, `{"error":"Not Found"}`+"\n", recorder.Body.String())
+}
+
+func TestLFSHandler(t *testing.T) {
+	handler := httperr.LFSHandler(statusErrorHandler(http.StatusNotFound))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, "application/vnd.git-lfs+json", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, `{"message":"test error"}`+"\n", recorder.Body.String())
+
+	handler = httperr.LFSHandler(errorHandler)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, `{"message":"Internal Server Error"}`+"\n", recorder.Body.String())
+}
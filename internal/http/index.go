@@ -2,10 +2,8 @@ package http
 
 import (
 	"net/http"
-	"os"
-	"sort"
+	"runtime"
 	"strings"
-	"time"
 
 	"go.jolheiser.com/ugit/internal/git"
 	"go.jolheiser.com/ugit/internal/html"
@@ -13,45 +11,11 @@ import (
 )
 
 func (rh repoHandler) index(w http.ResponseWriter, r *http.Request) error {
-	repoPaths, err := os.ReadDir(rh.s.RepoDir)
+	repos, err := rh.repos(r, r.URL.Query().Get("tag"))
 	if err != nil {
 		return httperr.Error(err)
 	}
 
-	tagFilter := r.URL.Query().Get("tag")
-
-	repos := make([]*git.Repo, 0, len(repoPaths))
-	for _, repoName := range repoPaths {
-		if !strings.HasSuffix(repoName.Name(), ".git") {
-			continue
-		}
-		repo, err := git.NewRepo(rh.s.RepoDir, repoName.Name())
-		if err != nil {
-			return httperr.Error(err)
-		}
-		if repo.Meta.Private {
-			if !rh.s.ShowPrivate {
-				continue
-			}
-			repo.Meta.Tags.Add("private")
-		}
-
-		if tagFilter != "" && !repo.Meta.Tags.Contains(strings.ToLower(tagFilter)) {
-			continue
-		}
-		repos = append(repos, repo)
-	}
-	sort.Slice(repos, func(i, j int) bool {
-		var when1, when2 time.Time
-		if c, err := repos[i].LastCommit(); err == nil {
-			when1 = c.When
-		}
-		if c, err := repos[j].LastCommit(); err == nil {
-			when2 = c.When
-		}
-		return when1.After(when2)
-	})
-
 	links := make([]html.IndexLink, 0, len(rh.s.Profile.Links))
 	for _, link := range rh.s.Profile.Links {
 		links = append(links, html.IndexLink{
@@ -61,7 +25,7 @@ func (rh repoHandler) index(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	if err := html.Index(html.IndexContext{
-		BaseContext: rh.baseContext(),
+		BaseContext: rh.baseContext(r),
 		Profile: html.IndexProfile{
 			Username: rh.s.Profile.Username,
 			Email:    rh.s.Profile.Email,
@@ -74,3 +38,90 @@ func (rh repoHandler) index(w http.ResponseWriter, r *http.Request) error {
 
 	return nil
 }
+
+// searchWorkers is the maximum number of repos searched at once
+var searchWorkers = runtime.NumCPU()
+
+func (rh repoHandler) search(w http.ResponseWriter, r *http.Request) error {
+	opts, err := grepOptions(r.URL.Query())
+	if err != nil {
+		return err
+	}
+	// Pagination is per repo, so the global search only shows the first page
+	opts.Offset = 0
+
+	var results []git.RepoGrepResult
+	query := r.URL.Query().Get("q")
+	if q := strings.TrimSpace(query); q != "" {
+		repos, err := rh.repos(r, "")
+		if err != nil {
+			return httperr.Error(err)
+		}
+		searchRepos := make([]*git.Repo, 0, len(repos))
+		for _, entry := range repos {
+			searchRepos = append(searchRepos, entry.Repo)
+		}
+		results, err = git.GrepRepos(r.Context(), searchRepos, q, opts, searchWorkers)
+		if err != nil {
+			return searchStatus(err)
+		}
+		for _, result := range results {
+			if err := highlightResults(result.Results); err != nil {
+				return httperr.Error(err)
+			}
+		}
+	}
+
+	bc := rh.baseContext(r)
+	bc.Title = "Search - " + bc.Title
+	if err := html.GlobalSearch(html.GlobalSearchContext{
+		BaseContext: bc,
+		Query:       query,
+		Results:     results,
+	}).Render(r.Context(), w); err != nil {
+		return httperr.Error(err)
+	}
+
+	return nil
+}
+
+// repos returns the repos visible to the request, optionally filtered by tag, most recently updated first
+// Private repos are tagged "private" for display, so they can be filtered by it as well
+func (rh repoHandler) repos(r *http.Request, tagFilter string) ([]git.CatalogEntry, error) {
+	entries, err := rh.visibleRepos(r)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make([]git.CatalogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Repo.Private() {
+			entry.Repo.Meta.Tags.Add("private")
+		}
+
+		if tagFilter != "" && !entry.Repo.Meta.Tags.Contains(strings.ToLower(tagFilter)) {
+			continue
+		}
+		repos = append(repos, entry)
+	}
+
+	return repos, nil
+}
+
+// visibleRepos returns the repos visible to the request with their tags as stored, most recently updated first
+func (rh repoHandler) visibleRepos(r *http.Request) ([]git.CatalogEntry, error) {
+	entries, err := rh.s.Catalog.Repos()
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make([]git.CatalogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Repo.Private() && !rh.canView(r, entry.Repo) {
+			continue
+		}
+		repos = append(repos, entry)
+	}
+
+	return repos, nil
+}
@@ -0,0 +1,183 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"go.jolheiser.com/ugit/internal/git"
+	"go.jolheiser.com/ugit/internal/http/httperr"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// lfsMediaType is the media type of Git LFS API requests and responses
+const lfsMediaType = "application/vnd.git-lfs+json"
+
+// lfsBatchRequest is a request to the LFS batch API
+type lfsBatchRequest struct {
+	Operation string      `json:"operation"`
+	Transfers []string    `json:"transfers"`
+	Objects   []lfsObject `json:"objects"`
+	HashAlgo  string      `json:"hash_algo"`
+}
+
+type lfsObject struct {
+	OID           string               `json:"oid"`
+	Size          int64                `json:"size"`
+	Authenticated bool                 `json:"authenticated,omitempty"`
+	Actions       map[string]lfsAction `json:"actions,omitempty"`
+	Error         *lfsObjectError      `json:"error,omitempty"`
+}
+
+type lfsAction struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header,omitempty"`
+}
+
+type lfsObjectError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// lfsBatchResponse is a response from the LFS batch API, only the basic transfer adapter is supported
+type lfsBatchResponse struct {
+	Transfer string      `json:"transfer"`
+	Objects  []lfsObject `json:"objects"`
+	HashAlgo string      `json:"hash_algo"`
+}
+
+// isLFSRequest returns whether a request is for the Git LFS API
+func isLFSRequest(r *http.Request) bool {
+	return strings.Contains(r.URL.Path, "/info/lfs/")
+}
+
+// lfsToken returns the token handed out by git-lfs-authenticate, if the request has one
+func lfsToken(r *http.Request) (string, bool) {
+	return strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+// authorizeLFSUpload ensures the request can upload objects to the repo
+func (rh repoHandler) authorizeLFSUpload(w http.ResponseWriter, r *http.Request, repo *git.Repo) error {
+	if rh.access(r, repo) >= git.ReadWriteAccess {
+		return nil
+	}
+	_, hasToken := lfsToken(r)
+	if _, ok := rh.user(r); !ok && !hasToken {
+		requireAuth(w)
+		return httperr.Status(errors.New("authentication required to upload"), http.StatusUnauthorized)
+	}
+	return httperr.Status(errors.New("not authorized to upload"), http.StatusForbidden)
+}
+
+func (rh repoHandler) lfsBatch(w http.ResponseWriter, r *http.Request) error {
+	repo := r.Context().Value(repoCtxKey).(*git.Repo)
+
+	var req lfsBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httperr.Status(fmt.Errorf("invalid batch request: %w", err), http.StatusBadRequest)
+	}
+	switch req.Operation {
+	case "download":
+	case "upload":
+		if err := rh.authorizeLFSUpload(w, r, repo); err != nil {
+			return err
+		}
+	default:
+		return httperr.Status(fmt.Errorf("unknown operation %q", req.Operation), http.StatusUnprocessableEntity)
+	}
+	if req.HashAlgo != "" && req.HashAlgo != "sha256" {
+		return httperr.Status(fmt.Errorf("unsupported hash algorithm %q", req.HashAlgo), http.StatusConflict)
+	}
+	if len(req.Transfers) > 0 && !slices.Contains(req.Transfers, "basic") {
+		return httperr.Status(errors.New("only the basic transfer adapter is supported"), http.StatusUnprocessableEntity)
+	}
+
+	// Transfers are authenticated the same way as the batch request
+	var header map[string]string
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		header = map[string]string{"Authorization": auth}
+	}
+	href := fmt.Sprintf("%s/%s.git/info/lfs/objects/", strings.TrimSuffix(rh.s.CloneURL, "/"), repo.Name())
+
+	resp := lfsBatchResponse{
+		Transfer: "basic",
+		Objects:  make([]lfsObject, 0, len(req.Objects)),
+		HashAlgo: "sha256",
+	}
+	for _, obj := range req.Objects {
+		obj.Actions, obj.Error = nil, nil
+		obj.Authenticated = header != nil
+		if !git.ValidLFSOID(obj.OID) || obj.Size < 0 {
+			obj.Error = &lfsObjectError{Code: http.StatusUnprocessableEntity, Message: "invalid oid or size"}
+			resp.Objects = append(resp.Objects, obj)
+			continue
+		}
+		size, err := repo.LFSObjectSize(obj.OID)
+		if err != nil && !errors.Is(err, git.ErrLFSObjectNotFound) {
+			return httperr.Error(err)
+		}
+		exists := err == nil && size == obj.Size
+		action := lfsAction{Href: href + obj.OID, Header: header}
+		switch {
+		case req.Operation == "download" && !exists:
+			obj.Error = &lfsObjectError{Code: http.StatusNotFound, Message: "object does not exist"}
+		case req.Operation == "download":
+			obj.Actions = map[string]lfsAction{"download": action}
+		case !exists:
+			obj.Actions = map[string]lfsAction{"upload": action}
+		}
+		resp.Objects = append(resp.Objects, obj)
+	}
+
+	w.Header().Set("Content-Type", lfsMediaType)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		return httperr.Error(err)
+	}
+	return nil
+}
+
+func (rh repoHandler) lfsDownload(w http.ResponseWriter, r *http.Request) error {
+	repo := r.Context().Value(repoCtxKey).(*git.Repo)
+	fi, err := repo.OpenLFSObject(chi.URLParam(r, "oid"))
+	if err != nil {
+		if errors.Is(err, git.ErrLFSObjectNotFound) {
+			return httperr.Status(err, http.StatusNotFound)
+		}
+		return httperr.Error(err)
+	}
+	defer fi.Close()
+	stat, err := fi.Stat()
+	if err != nil {
+		return httperr.Error(err)
+	}
+
+	// Browsers are linked here from the file view, which names the download
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if name := r.URL.Query().Get("name"); name != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	}
+	http.ServeContent(w, r, "", stat.ModTime(), fi)
+	return nil
+}
+
+func (rh repoHandler) lfsUpload(w http.ResponseWriter, r *http.Request) error {
+	repo := r.Context().Value(repoCtxKey).(*git.Repo)
+	if err := rh.authorizeLFSUpload(w, r, repo); err != nil {
+		return err
+	}
+	if r.ContentLength < 0 {
+		return httperr.Status(errors.New("content length is required"), http.StatusLengthRequired)
+	}
+	if err := repo.StoreLFSObject(chi.URLParam(r, "oid"), r.ContentLength, r.Body); err != nil {
+		if errors.Is(err, git.ErrLFSObjectMismatch) {
+			return httperr.Status(err, http.StatusUnprocessableEntity)
+		}
+		return httperr.Error(err)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io/fs"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"go.jolheiser.com/ugit/internal/git"
@@ -17,19 +18,11 @@ var repoCtxKey = ugitCtxKey("repo")
 
 func (rh repoHandler) repoMiddleware(next http.Handler) http.Handler {
 	return httperr.Handler(func(w http.ResponseWriter, r *http.Request) error {
-		repoName := chi.URLParam(r, "repo")
-		repo, err := git.NewRepo(rh.s.RepoDir, repoName)
+		repo, err := rh.repo(w, r)
 		if err != nil {
-			httpErr := http.StatusInternalServerError
-			if errors.Is(err, fs.ErrNotExist) {
-				httpErr = http.StatusNotFound
-			}
-			return httperr.Status(err, httpErr)
+			return err
 		}
-		if repo.Meta.Private {
-			if !rh.s.ShowPrivate {
-				return httperr.Status(errors.New("could not get git repo"), http.StatusNotFound)
-			}
+		if repo.Private() {
 			repo.Meta.Tags.Add("private")
 		}
 		r = r.WithContext(context.WithValue(r.Context(), repoCtxKey, repo))
@@ -37,3 +30,44 @@ func (rh repoHandler) repoMiddleware(next http.Handler) http.Handler {
 		return nil
 	})
 }
+
+// apiRepoMiddleware is like repoMiddleware, but responds to errors with JSON and leaves the repo's tags as stored
+func (rh repoHandler) apiRepoMiddleware(next http.Handler) http.Handler {
+	return httperr.JSONHandler(func(w http.ResponseWriter, r *http.Request) error {
+		repo, err := rh.repo(w, r)
+		if err != nil {
+			return err
+		}
+		r = r.WithContext(context.WithValue(r.Context(), repoCtxKey, repo))
+		next.ServeHTTP(w, r)
+		return nil
+	})
+}
+
+// repo opens the repo in the URL, if the request can view it
+func (rh repoHandler) repo(w http.ResponseWriter, r *http.Request) (*git.Repo, error) {
+	repoName := chi.URLParam(r, "repo")
+	repo, err := git.NewRepo(rh.s.RepoDir, repoName)
+	if err != nil {
+		httpErr := http.StatusInternalServerError
+		if errors.Is(err, fs.ErrNotExist) {
+			httpErr = http.StatusNotFound
+		}
+		return nil, httperr.Status(err, httpErr)
+	}
+	if repo.Private() && !rh.canView(r, repo) {
+		// Git clients and feed readers only send credentials once asked for them
+		if _, ok := rh.user(r); !ok && (isGitRequest(r) || isFeedRequest(r)) {
+			requireAuth(w)
+			return nil, httperr.Status(errors.New("authentication required"), http.StatusUnauthorized)
+		}
+		return nil, httperr.Status(errors.New("could not get git repo"), http.StatusNotFound)
+	}
+	return repo, nil
+}
+
+// isGitRequest returns whether a request is for the git smart HTTP protocol or Git LFS
+func isGitRequest(r *http.Request) bool {
+	path := r.URL.Path
+	return strings.HasSuffix(path, "/info/refs") || strings.HasSuffix(path, "/git-upload-pack") || strings.HasSuffix(path, "/git-receive-pack") || isLFSRequest(r)
+}
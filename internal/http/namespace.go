@@ -0,0 +1,110 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.jolheiser.com/ugit/internal/git"
+	"go.jolheiser.com/ugit/internal/html"
+	"go.jolheiser.com/ugit/internal/http/httperr"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// resolvePath splits a path into a repo name and the rest of the path, walking through namespaces until a repo is found
+// The repo name keeps a .git suffix if the path had one, if no repo is found the path may be a namespace
+func (rh repoHandler) resolvePath(path string) (repo string, rest string, namespace bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	// A trailing slash on a namespace is ignored, the rest of a repo path is kept as-is
+	if len(parts) > 1 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	for idx, part := range parts {
+		if part == "" || strings.HasPrefix(part, ".") {
+			return "", "", false
+		}
+		name := strings.Join(parts[:idx+1], "/")
+		repoPath := filepath.Join(rh.s.RepoDir, filepath.FromSlash(name))
+		if !strings.HasSuffix(repoPath, ".git") {
+			repoPath += ".git"
+		}
+		if fi, err := os.Stat(repoPath); err == nil && fi.IsDir() {
+			_, rest, _ := strings.Cut(strings.TrimPrefix(path, "/"), name)
+			return name, "/" + strings.TrimPrefix(rest, "/"), false
+		}
+		if fi, err := os.Stat(filepath.Join(rh.s.RepoDir, filepath.FromSlash(name))); err != nil || !fi.IsDir() {
+			return "", "", false
+		}
+	}
+	return strings.Join(parts, "/"), "", true
+}
+
+// namespaceRouter routes requests to the repo routes, which see the repo as the "repo" URL param,
+// or to the namespace page if nsHandler is set and the path is a namespace
+func (rh repoHandler) namespaceRouter(repoRoutes http.Handler, nsHandler func(http.ResponseWriter, *http.Request) error) func(http.ResponseWriter, *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		rctx := chi.RouteContext(r.Context())
+		name, rest, namespace := rh.resolvePath(chi.URLParam(r, "*"))
+		switch {
+		case name == "" || (namespace && nsHandler == nil):
+			return httperr.Status(errors.New("not found"), http.StatusNotFound)
+		case namespace:
+			rctx.URLParams.Add("namespace", name)
+			return nsHandler(w, r)
+		}
+
+		// Like chi's Mount, the sub-router only sees the rest of the path
+		if n := len(rctx.URLParams.Keys) - 1; n >= 0 && rctx.URLParams.Keys[n] == "*" {
+			rctx.URLParams.Values[n] = ""
+		}
+		rctx.URLParams.Add("repo", name)
+		rctx.RoutePath = rest
+		repoRoutes.ServeHTTP(w, r)
+		return nil
+	}
+}
+
+func (rh repoHandler) namespace(w http.ResponseWriter, r *http.Request) error {
+	name := chi.URLParam(r, "namespace")
+	ns, err := git.NewNamespace(rh.s.RepoDir, name)
+	if err != nil {
+		return httperr.Status(err, http.StatusNotFound)
+	}
+	entries, err := rh.repos(r, "")
+	if err != nil {
+		return httperr.Error(err)
+	}
+	var visible bool
+	tagFilter := strings.ToLower(r.URL.Query().Get("tag"))
+	repos := make([]git.CatalogEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Repo.Name(), ns.Name()+"/") {
+			continue
+		}
+		visible = true
+		if tagFilter == "" || entry.Repo.Meta.Tags.Contains(tagFilter) {
+			repos = append(repos, entry)
+		}
+	}
+	// A private namespace is hidden from anyone who can't see any of its repos
+	if ns.Private() && !visible && !rh.s.ShowPrivate {
+		return httperr.Status(errors.New("could not get namespace"), http.StatusNotFound)
+	}
+
+	bc := rh.baseContext(r)
+	bc.Title = ns.Name()
+	bc.Description = ns.Meta.Description
+	if err := html.Namespace(html.NamespaceContext{
+		BaseContext: bc,
+		Name:        ns.Name(),
+		Description: ns.Meta.Description,
+		Private:     ns.Private(),
+		Repos:       repos,
+	}).Render(r.Context(), w); err != nil {
+		return httperr.Error(err)
+	}
+	return nil
+}
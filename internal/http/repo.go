@@ -3,10 +3,16 @@ package http
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"log/slog"
 	"mime"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"regexp/syntax"
+	"strconv"
 	"strings"
+	"time"
 
 	"go.jolheiser.com/ugit/internal/html/markup"
 
@@ -14,7 +20,9 @@ import (
 	"go.jolheiser.com/ugit/internal/html"
 	"go.jolheiser.com/ugit/internal/http/httperr"
 
+	"github.com/dustin/go-humanize"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
@@ -48,7 +56,7 @@ func (rh repoHandler) repoTree(ref, path string) http.HandlerFunc {
 			back = filepath.Dir(path)
 		}
 		if err := html.RepoTree(html.RepoTreeContext{
-			BaseContext:                    rh.repoBaseContext(repo),
+			BaseContext:                    rh.repoBaseContext(repo, r),
 			RepoHeaderComponentContext:     rh.repoHeaderContext(repo, r),
 			RepoBreadcrumbComponentContext: rh.repoBreadcrumbContext(repo, r, path),
 			RepoTreeComponentContext: html.RepoTreeComponentContext{
@@ -68,6 +76,9 @@ func (rh repoHandler) repoTree(ref, path string) http.HandlerFunc {
 	})
 }
 
+// lfsRenderLimit is the largest LFS object shown in the file view, larger objects can only be downloaded
+var lfsRenderLimit int64 = 1 << 20
+
 func (rh repoHandler) repoFile(w http.ResponseWriter, r *http.Request, repo *git.Repo, ref, path string) error {
 	content, err := repo.FileContent(ref, path)
 	if err != nil {
@@ -77,6 +88,32 @@ func (rh repoHandler) repoFile(w http.ResponseWriter, r *http.Request, repo *git
 		return httperr.Error(err)
 	}
 
+	// LFS pointers are shown as the object they point to, when it is small enough and not binary
+	var lfs *html.RepoFileLFS
+	if ptr, ok := git.ParseLFSPointer([]byte(content)); ok {
+		lfs = &html.RepoFileLFS{
+			Size:     humanize.Bytes(uint64(ptr.Size)),
+			Download: fmt.Sprintf("/%s/info/lfs/objects/%s?%s", repo.Name(), ptr.OID, url.Values{"name": {filepath.Base(path)}}.Encode()),
+		}
+		content = ""
+		if size, err := repo.LFSObjectSize(ptr.OID); err == nil && size == ptr.Size {
+			lfs.Stored = true
+			if r.URL.Query().Has("raw") {
+				http.Redirect(w, r, lfs.Download, http.StatusFound)
+				return nil
+			}
+			if size <= lfsRenderLimit {
+				object, err := repo.LFSObjectContent(ptr.OID)
+				if err != nil {
+					return httperr.Error(err)
+				}
+				if !strings.ContainsRune(object, 0) {
+					content = object
+				}
+			}
+		}
+	}
+
 	if r.URL.Query().Has("raw") {
 		if r.URL.Query().Has("pretty") {
 			ext := filepath.Ext(path)
@@ -87,8 +124,10 @@ func (rh repoHandler) repoFile(w http.ResponseWriter, r *http.Request, repo *git
 	}
 
 	var buf bytes.Buffer
-	if err := markup.Convert([]byte(content), filepath.Base(path), "L", &buf); err != nil {
-		return httperr.Error(err)
+	if lfs == nil || content != "" {
+		if err := markup.Convert([]byte(content), filepath.Base(path), "L", &buf); err != nil {
+			return httperr.Error(err)
+		}
 	}
 
 	commit := ref
@@ -100,12 +139,59 @@ func (rh repoHandler) repoFile(w http.ResponseWriter, r *http.Request, repo *git
 	}
 
 	if err := html.RepoFile(html.RepoFileContext{
-		BaseContext:                    rh.repoBaseContext(repo),
+		BaseContext:                    rh.repoBaseContext(repo, r),
 		RepoHeaderComponentContext:     rh.repoHeaderContext(repo, r),
 		RepoBreadcrumbComponentContext: rh.repoBreadcrumbContext(repo, r, path),
 		Code:                           buf.String(),
 		Commit:                         commit,
 		Path:                           path,
+		LFS:                            lfs,
+	}).Render(r.Context(), w); err != nil {
+		return httperr.Error(err)
+	}
+
+	return nil
+}
+
+func (rh repoHandler) repoBlame(w http.ResponseWriter, r *http.Request) error {
+	repo := r.Context().Value(repoCtxKey).(*git.Repo)
+	ref, path := chi.URLParam(r, "ref"), chi.URLParam(r, "*")
+
+	content, err := repo.FileContent(ref, path)
+	if err != nil {
+		if errors.Is(err, object.ErrFileNotFound) {
+			return httperr.Status(err, http.StatusNotFound)
+		}
+		return httperr.Error(err)
+	}
+
+	hunks, err := repo.Blame(ref, path)
+	if err != nil {
+		return httperr.Error(err)
+	}
+
+	sizes := make([]int, 0, len(hunks))
+	for _, hunk := range hunks {
+		sizes = append(sizes, len(hunk.Lines))
+	}
+	code, err := markup.Hunks([]byte(content), filepath.Base(path), "L", sizes)
+	if err != nil {
+		return httperr.Error(err)
+	}
+
+	blameHunks := make([]html.RepoBlameHunk, 0, len(hunks))
+	for idx, hunk := range hunks {
+		blameHunks = append(blameHunks, html.RepoBlameHunk{
+			BlameHunk: hunk,
+			Code:      code[idx],
+		})
+	}
+
+	if err := html.RepoBlame(html.RepoBlameContext{
+		BaseContext:                    rh.repoBaseContext(repo, r),
+		RepoHeaderComponentContext:     rh.repoHeaderContext(repo, r),
+		RepoBreadcrumbComponentContext: rh.repoBreadcrumbContext(repo, r, path),
+		Hunks:                          blameHunks,
 	}).Render(r.Context(), w); err != nil {
 		return httperr.Error(err)
 	}
@@ -125,9 +211,13 @@ func (rh repoHandler) repoRefs(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return httperr.Error(err)
 	}
+	verifier := rh.verifier()
+	for idx := range tags {
+		tags[idx].Verify(verifier)
+	}
 
 	if err := html.RepoRefs(html.RepoRefsContext{
-		BaseContext:                rh.repoBaseContext(repo),
+		BaseContext:                rh.repoBaseContext(repo, r),
 		RepoHeaderComponentContext: rh.repoHeaderContext(repo, r),
 		Branches:                   branches,
 		Tags:                       tags,
@@ -138,18 +228,136 @@ func (rh repoHandler) repoRefs(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// verifier loads the signing keys, which are re-read on every request so changes don't require a restart
+// Signatures can still be shown if the keys can't be loaded, they just can't be verified
+func (rh repoHandler) verifier() *git.Verifier {
+	verifier, err := rh.s.Signing.Load()
+	if err != nil {
+		slog.Error("could not load signing keys", "error", err)
+	}
+	return verifier
+}
+
+func (rh repoHandler) repoArchive(w http.ResponseWriter, r *http.Request) error {
+	repo := r.Context().Value(repoCtxKey).(*git.Repo)
+
+	ref, format, err := git.ParseArchive(chi.URLParam(r, "*"))
+	if err != nil {
+		return httperr.Status(err, http.StatusNotFound)
+	}
+	if _, err := repo.GetCommitFromRef(ref); err != nil {
+		return httperr.Status(err, http.StatusNotFound)
+	}
+
+	contentType := "application/gzip"
+	if format == git.ArchiveZip {
+		contentType = "application/zip"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": fmt.Sprintf("%s.%s", repo.ArchivePrefix(ref), format),
+	}))
+	if err := repo.Archive(w, ref, format); err != nil {
+		return httperr.Error(err)
+	}
+
+	return nil
+}
+
+const (
+	// defaultLogLimit is the number of commits shown per log page
+	defaultLogLimit = 50
+	// maxLogLimit is the maximum number of commits that can be requested per log page
+	maxLogLimit = 500
+	// defaultSearchLimit is the number of results shown per search page
+	defaultSearchLimit = 50
+	// maxSearchLimit is the maximum number of results that can be requested per search page
+	maxSearchLimit = 500
+	// defaultSearchContext is the number of lines shown around each search result
+	defaultSearchContext = 1
+	// maxSearchContext is the maximum number of lines that can be requested around each search result
+	maxSearchContext = 10
+)
+
+// logOptions parses the log filters and pagination from a query
+func logOptions(query url.Values) (git.LogOptions, error) {
+	opts := git.LogOptions{
+		Author: query.Get("author"),
+		After:  query.Get("after"),
+		Limit:  defaultLogLimit,
+	}
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			return opts, httperr.Status(fmt.Errorf("invalid limit %q", l), http.StatusBadRequest)
+		}
+		opts.Limit = min(limit, maxLogLimit)
+	}
+	if opts.After != "" && !plumbing.IsHash(opts.After) {
+		return opts, httperr.Status(fmt.Errorf("invalid cursor %q", opts.After), http.StatusBadRequest)
+	}
+	if s := query.Get("since"); s != "" {
+		since, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			return opts, httperr.Status(err, http.StatusBadRequest)
+		}
+		opts.Since = &since
+	}
+	if u := query.Get("until"); u != "" {
+		until, err := time.Parse(time.DateOnly, u)
+		if err != nil {
+			return opts, httperr.Status(err, http.StatusBadRequest)
+		}
+		// Include the entire day
+		until = until.Add(24*time.Hour - time.Nanosecond)
+		opts.Until = &until
+	}
+	return opts, nil
+}
+
 func (rh repoHandler) repoLog(w http.ResponseWriter, r *http.Request) error {
 	repo := r.Context().Value(repoCtxKey).(*git.Repo)
 
-	commits, err := repo.Commits(chi.URLParam(r, "ref"))
+	ref, path := chi.URLParam(r, "ref"), chi.URLParam(r, "*")
+	if path != "" {
+		ref, path = repo.SplitRefPath(ref + "/" + path)
+	}
+	query := r.URL.Query()
+	if name, suffix, ok := parseFeed(ref); ok && path == "" {
+		return rh.repoLogFeed(w, r, name, suffix)
+	}
+
+	opts, err := logOptions(query)
+	if err != nil {
+		return err
+	}
+	opts.Path = path
+
+	commits, next, err := repo.Commits(ref, opts)
 	if err != nil {
 		return httperr.Error(err)
 	}
+	verifier := rh.verifier()
+	for idx := range commits {
+		commits[idx].Verify(verifier)
+	}
+
+	var nextURL string
+	if next != "" {
+		query.Set("after", next)
+		nextURL = r.URL.Path + "?" + query.Encode()
+	}
 
 	if err := html.RepoLog(html.RepoLogContext{
-		BaseContext:                rh.repoBaseContext(repo),
+		BaseContext:                rh.repoBaseContext(repo, r),
 		RepoHeaderComponentContext: rh.repoHeaderContext(repo, r),
 		Commits:                    commits,
+		Ref:                        ref,
+		Path:                       path,
+		Author:                     opts.Author,
+		Since:                      query.Get("since"),
+		Until:                      query.Get("until"),
+		NextURL:                    nextURL,
 	}).Render(r.Context(), w); err != nil {
 		return httperr.Error(err)
 	}
@@ -160,23 +368,37 @@ func (rh repoHandler) repoLog(w http.ResponseWriter, r *http.Request) error {
 func (rh repoHandler) repoCommit(w http.ResponseWriter, r *http.Request) error {
 	repo := r.Context().Value(repoCtxKey).(*git.Repo)
 
-	commit, err := repo.Commit(chi.URLParam(r, "commit"))
+	parent := 0
+	switch p := r.URL.Query().Get("parent"); p {
+	case "":
+	case "combined":
+		parent = git.CombinedDiff
+	default:
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return httperr.Status(err, http.StatusBadRequest)
+		}
+		parent = n - 1
+	}
+
+	commit, err := repo.CommitDiff(chi.URLParam(r, "commit"), parent)
 	if err != nil {
+		if errors.Is(err, git.ErrInvalidParent) {
+			return httperr.Status(err, http.StatusNotFound)
+		}
 		return httperr.Error(err)
 	}
 
-	for idx, p := range commit.Files {
-		var patch bytes.Buffer
-		if err := markup.Convert([]byte(p.Patch), "commit.patch", p.Path()+"-L", &patch); err != nil {
-			return httperr.Error(err)
-		}
-		commit.Files[idx].Patch = patch.String()
+	if err := highlightFiles(commit.Files); err != nil {
+		return httperr.Error(err)
 	}
+	commit.Verify(rh.verifier())
 
 	if err := html.RepoCommit(html.RepoCommitContext{
-		BaseContext:                rh.repoBaseContext(repo),
+		BaseContext:                rh.repoBaseContext(repo, r),
 		RepoHeaderComponentContext: rh.repoHeaderContext(repo, r),
 		Commit:                     commit,
+		Parent:                     parent,
 	}).Render(r.Context(), w); err != nil {
 		return httperr.Error(err)
 	}
@@ -197,30 +419,166 @@ func (rh repoHandler) repoPatch(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+func (rh repoHandler) repoCompare(w http.ResponseWriter, r *http.Request) error {
+	repo := r.Context().Value(repoCtxKey).(*git.Repo)
+
+	base, head, raw, err := git.ParseCompare(chi.URLParam(r, "*"))
+	if err != nil {
+		return httperr.Status(err, http.StatusBadRequest)
+	}
+
+	if raw {
+		var buf bytes.Buffer
+		if err := repo.PatchSeries(&buf, base, head); err != nil {
+			if errors.Is(err, plumbing.ErrReferenceNotFound) {
+				return httperr.Status(err, http.StatusNotFound)
+			}
+			return httperr.Error(err)
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(buf.Bytes())
+		return nil
+	}
+
+	comparison, err := repo.Compare(base, head)
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return httperr.Status(err, http.StatusNotFound)
+		}
+		return httperr.Error(err)
+	}
+
+	if err := highlightFiles(comparison.Files); err != nil {
+		return httperr.Error(err)
+	}
+	verifier := rh.verifier()
+	for idx := range comparison.Commits {
+		comparison.Commits[idx].Verify(verifier)
+	}
+
+	if err := html.RepoCompare(html.RepoCompareContext{
+		BaseContext:                rh.repoBaseContext(repo, r),
+		RepoHeaderComponentContext: rh.repoHeaderContext(repo, r),
+		Comparison:                 comparison,
+	}).Render(r.Context(), w); err != nil {
+		return httperr.Error(err)
+	}
+
+	return nil
+}
+
+// highlightFiles replaces each file patch with its highlighted HTML
+func highlightFiles(files []git.CommitFile) error {
+	for idx, p := range files {
+		var patch bytes.Buffer
+		if err := markup.Convert([]byte(p.Patch), "commit.patch", p.Path()+"-L", &patch); err != nil {
+			return err
+		}
+		files[idx].Patch = patch.String()
+	}
+	return nil
+}
+
+// highlightResults replaces each result's content with its highlighted HTML
+func highlightResults(results []git.GrepResult) error {
+	for idx, result := range results {
+		var buf bytes.Buffer
+		if err := markup.Snippet([]byte(result.Content), filepath.Base(result.File), result.StartLine, &buf); err != nil {
+			return err
+		}
+		results[idx].Content = buf.String()
+	}
+	return nil
+}
+
+// grepOptions parses the search context and pagination from a query
+func grepOptions(query url.Values) (git.GrepOptions, error) {
+	opts := git.GrepOptions{
+		Context: defaultSearchContext,
+		Limit:   defaultSearchLimit,
+	}
+	if c := query.Get("context"); c != "" {
+		context, err := strconv.Atoi(c)
+		if err != nil || context < 0 {
+			return opts, httperr.Status(fmt.Errorf("invalid context %q", c), http.StatusBadRequest)
+		}
+		opts.Context = min(context, maxSearchContext)
+	}
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			return opts, httperr.Status(fmt.Errorf("invalid limit %q", l), http.StatusBadRequest)
+		}
+		opts.Limit = min(limit, maxSearchLimit)
+	}
+	if p := query.Get("page"); p != "" {
+		page, err := strconv.Atoi(p)
+		if err != nil || page < 1 {
+			return opts, httperr.Status(fmt.Errorf("invalid page %q", p), http.StatusBadRequest)
+		}
+		opts.Offset = (page - 1) * opts.Limit
+	}
+	return opts, nil
+}
+
+// searchStatus maps an invalid search to a 400 and a missing ref to a 404
+func searchStatus(err error) error {
+	var syntaxErr *syntax.Error
+	if errors.As(err, &syntaxErr) || errors.Is(err, git.ErrInvalidSearch) {
+		return httperr.Status(err, http.StatusBadRequest)
+	}
+	return apiStatus(err)
+}
+
 func (rh repoHandler) repoSearch(w http.ResponseWriter, r *http.Request) error {
 	repo := r.Context().Value(repoCtxKey).(*git.Repo)
+	header := rh.repoHeaderContext(repo, r)
+
+	query := r.URL.Query()
+	opts, err := grepOptions(query)
+	if err != nil {
+		return err
+	}
 
 	var results []git.GrepResult
-	search := r.URL.Query().Get("q")
-	if q := strings.TrimSpace(search); q != "" {
-		var err error
-		results, err = repo.Grep(q)
+	var prevURL, nextURL string
+	ref := header.Ref
+	if q := strings.TrimSpace(query.Get("q")); q != "" {
+		search, err := git.ParseSearch(q)
 		if err != nil {
+			return searchStatus(err)
+		}
+		if search.Ref != "" {
+			ref = search.Ref
+		}
+
+		var more bool
+		results, more, err = repo.Grep(q, opts)
+		if err != nil {
+			return searchStatus(err)
+		}
+		if err := highlightResults(results); err != nil {
 			return httperr.Error(err)
 		}
-		for idx, result := range results {
-			var buf bytes.Buffer
-			if err := markup.Snippet([]byte(result.Content), filepath.Base(result.File), result.StartLine, &buf); err != nil {
-				return httperr.Error(err)
-			}
-			results[idx].Content = buf.String()
+
+		page := opts.Offset/opts.Limit + 1
+		if page > 1 {
+			query.Set("page", strconv.Itoa(page-1))
+			prevURL = r.URL.Path + "?" + query.Encode()
+		}
+		if more {
+			query.Set("page", strconv.Itoa(page+1))
+			nextURL = r.URL.Path + "?" + query.Encode()
 		}
 	}
 
 	if err := html.RepoSearch(html.SearchContext{
-		BaseContext:                rh.repoBaseContext(repo),
-		RepoHeaderComponentContext: rh.repoHeaderContext(repo, r),
+		BaseContext:                rh.repoBaseContext(repo, r),
+		RepoHeaderComponentContext: header,
+		Ref:                        ref,
 		Results:                    results,
+		PrevURL:                    prevURL,
+		NextURL:                    nextURL,
 	}).Render(r.Context(), w); err != nil {
 		return httperr.Error(err)
 	}
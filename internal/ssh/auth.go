@@ -0,0 +1,130 @@
+package ssh
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/ssh"
+	"go.jolheiser.com/ugit/internal/git"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Identity is who a public key belongs to
+type Identity struct {
+	Name        string
+	Fingerprint string
+	Admin       bool
+}
+
+// Identities returns the names an Identity can be referred to by in a repo ACL
+func (i Identity) Identities() []string {
+	return []string{i.Fingerprint, i.Name}
+}
+
+type identityCtxKey struct{}
+
+// pusher returns the git.Pusher for a session, using the Identity found during authentication
+func pusher(s ssh.Session) git.Pusher {
+	if s.PublicKey() == nil {
+		return git.Pusher{}
+	}
+	p := git.Pusher{
+		Fingerprint: gossh.FingerprintSHA256(s.PublicKey()),
+	}
+	if id, ok := s.Context().Value(identityCtxKey{}).(Identity); ok && id.Fingerprint == p.Fingerprint {
+		p.Name = id.Name
+	}
+	return p
+}
+
+// Keyring looks up the Identity of public keys.
+// Keys in AuthorizedKeys are admins and have access to everything,
+// keys in UserKeys are named by their comment and limited to the repo ACLs
+// unless their name is one of the Admins.
+// Both files are re-read on every lookup so changes don't require a restart.
+type Keyring struct {
+	AuthorizedKeys string
+	UserKeys       string
+	Admins         []string
+}
+
+// Lookup returns the Identity for a given public key, if any
+func (k Keyring) Lookup(pk ssh.PublicKey) (Identity, bool) {
+	if pk == nil {
+		return Identity{}, false
+	}
+	fingerprint := gossh.FingerprintSHA256(pk)
+	for _, path := range []string{k.AuthorizedKeys, k.UserKeys} {
+		if path == "" {
+			continue
+		}
+		keys, err := readAuthorizedKeys(path)
+		if err != nil {
+			continue
+		}
+		for _, key := range keys {
+			if ssh.KeysEqual(pk, key.key) {
+				return Identity{
+					Name:        key.comment,
+					Fingerprint: fingerprint,
+					Admin:       path == k.AuthorizedKeys || slices.Contains(k.Admins, key.comment),
+				}, true
+			}
+		}
+	}
+	return Identity{}, false
+}
+
+type authorizedKey struct {
+	key     ssh.PublicKey
+	comment string
+}
+
+func readAuthorizedKeys(path string) ([]authorizedKey, error) {
+	fi, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer fi.Close()
+
+	var keys []authorizedKey
+	scanner := bufio.NewScanner(fi)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, authorizedKey{
+			key:     key,
+			comment: comment,
+		})
+	}
+	return keys, scanner.Err()
+}
+
+// AuthRepo returns the AccessLevel a public key has for a given repo
+func (k Keyring) AuthRepo(repoDir, repoName string, pk ssh.PublicKey) git.AccessLevel {
+	id, ok := k.Lookup(pk)
+	if !ok {
+		return git.NoAccess
+	}
+	if id.Admin {
+		return git.AdminAccess
+	}
+	repo, err := git.NewRepo(repoDir, repoName)
+	if err != nil {
+		return git.NoAccess
+	}
+	return repo.Access(id.Identities()...)
+}
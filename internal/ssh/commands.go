@@ -0,0 +1,223 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.jolheiser.com/ugit/internal/git"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+const repoUsage = `usage: repo <command> <name> [args...]
+
+names may include namespaces, e.g. group/repo
+
+commands:
+  create <name>                        create a new private repo
+  delete <name>                        delete a repo
+  rename <name> <new-name>             rename a repo or move it to another namespace
+  describe <name> [description...]     show or set the description
+  set-private <name> [true|false]      show or set whether the repo is private
+  tag <name> [tag|-tag...]             list, add, or remove tags
+  set-default-branch <name> [branch]   show or set the default branch`
+
+// repoCommand runs a repo management command, e.g. "repo create foo"
+// Creating, deleting, and renaming repos and changing who can see them or what is served by default requires admin access,
+// while changing the description or tags of a repo requires write access
+func repoCommand(s ssh.Session, repoDir, cloneURL string, gh Hooks, args []string) error {
+	if len(args) < 2 {
+		return errors.New(repoUsage)
+	}
+	cmd, name, args := args[0], strings.TrimSuffix(args[1], ".git"), args[2:]
+	if !validName(name) {
+		return ErrInvalidRepo
+	}
+	access := gh.AuthRepo(name, s.PublicKey())
+
+	if cmd == "create" {
+		if access < git.AdminAccess {
+			return ErrUnauthorized
+		}
+		repo, err := git.CreateRepo(repoDir, name)
+		if err != nil {
+			return commandError(err)
+		}
+		wish.Printf(s, "%s/%s.git\n", cloneURL, repo.Name())
+		return nil
+	}
+
+	if access == git.NoAccess {
+		return ErrInvalidRepo
+	}
+	repo, err := git.NewRepo(repoDir, name)
+	if err != nil {
+		return ErrInvalidRepo
+	}
+
+	required, ok := requiredAccess(cmd, args)
+	if !ok {
+		return errors.New(repoUsage)
+	}
+	if access < required {
+		return ErrUnauthorized
+	}
+
+	switch cmd {
+	case "delete":
+		if err := repo.Delete(); err != nil {
+			return commandError(err)
+		}
+		wish.Printf(s, "deleted %s\n", repo.Name())
+	case "rename":
+		if len(args) != 1 {
+			return errors.New(repoUsage)
+		}
+		if err := repo.Rename(args[0]); err != nil {
+			return commandError(err)
+		}
+		wish.Printf(s, "%s/%s.git\n", cloneURL, repo.Name())
+	case "describe":
+		if len(args) > 0 {
+			repo.Meta.Description = strings.Join(args, " ")
+			if err := repo.SaveMeta(); err != nil {
+				return commandError(err)
+			}
+		}
+		wish.Println(s, repo.Meta.Description)
+	case "set-private":
+		if len(args) > 0 {
+			private, err := strconv.ParseBool(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid value %q, expected true or false", args[0])
+			}
+			repo.Meta.Private = private
+			if err := repo.SaveMeta(); err != nil {
+				return commandError(err)
+			}
+		}
+		wish.Println(s, repo.Meta.Private)
+	case "tag":
+		if len(args) > 0 {
+			for _, tag := range args {
+				tag = strings.ToLower(tag)
+				if remove, ok := strings.CutPrefix(tag, "-"); ok {
+					repo.Meta.Tags.Remove(remove)
+					continue
+				}
+				repo.Meta.Tags.Add(tag)
+			}
+			if err := repo.SaveMeta(); err != nil {
+				return commandError(err)
+			}
+		}
+		wish.Println(s, strings.Join(repo.Meta.Tags.Slice(), " "))
+	case "set-default-branch":
+		if len(args) > 0 {
+			if err := repo.SetDefaultBranch(args[0]); err != nil {
+				if errors.Is(err, plumbing.ErrReferenceNotFound) {
+					return fmt.Errorf("unknown branch %q", args[0])
+				}
+				return commandError(err)
+			}
+		}
+		branch, err := repo.DefaultBranch()
+		if err != nil {
+			return commandError(err)
+		}
+		wish.Println(s, branch)
+	}
+	return nil
+}
+
+// requiredAccess returns the AccessLevel needed to run a repo command other than create, or false for an unknown command
+func requiredAccess(cmd string, args []string) (git.AccessLevel, bool) {
+	switch cmd {
+	case "delete", "rename":
+		return git.AdminAccess, true
+	case "describe", "set-private", "tag", "set-default-branch":
+		// Commands without arguments show the current value, which only requires read access
+		if len(args) == 0 {
+			return git.ReadOnlyAccess, true
+		}
+		if cmd == "set-private" || cmd == "set-default-branch" {
+			return git.AdminAccess, true
+		}
+		return git.ReadWriteAccess, true
+	}
+	return git.NoAccess, false
+}
+
+const namespaceUsage = `usage: namespace <command> <name> [args...]
+
+commands:
+  describe <name> [description...]     show or set the description
+  set-private <name> [true|false]      show or set whether every repo in the namespace is private`
+
+// namespaceCommand runs a namespace management command, e.g. "namespace describe group Our repos"
+// Changing a namespace requires admin access, anyone can see the meta of a public namespace
+func namespaceCommand(s ssh.Session, repoDir string, gh Hooks, args []string) error {
+	if len(args) < 2 {
+		return errors.New(namespaceUsage)
+	}
+	cmd, name, args := args[0], args[1], args[2:]
+	if !validName(name) {
+		return ErrInvalidNamespace
+	}
+	// Admins have admin access to any name, repo or not
+	admin := gh.AuthRepo(name, s.PublicKey()) == git.AdminAccess
+	ns, err := git.NewNamespace(repoDir, name)
+	if err != nil || (ns.Private() && !admin) {
+		return ErrInvalidNamespace
+	}
+	if cmd != "describe" && cmd != "set-private" {
+		return errors.New(namespaceUsage)
+	}
+	if len(args) > 0 && !admin {
+		return ErrUnauthorized
+	}
+
+	switch cmd {
+	case "describe":
+		if len(args) > 0 {
+			ns.Meta.Description = strings.Join(args, " ")
+			if err := ns.SaveMeta(); err != nil {
+				return commandError(err)
+			}
+		}
+		wish.Println(s, ns.Meta.Description)
+	case "set-private":
+		if len(args) > 0 {
+			private, err := strconv.ParseBool(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid value %q, expected true or false", args[0])
+			}
+			ns.Meta.Private = private
+			if err := ns.SaveMeta(); err != nil {
+				return commandError(err)
+			}
+		}
+		wish.Println(s, ns.Meta.Private)
+	}
+	return nil
+}
+
+// validName returns whether a repo or namespace name stays within the repo dir
+func validName(name string) bool {
+	return name != "." && !strings.Contains(name, `\`) && filepath.IsLocal(name)
+}
+
+// commandError hides unexpected errors from the client, logging them instead
+func commandError(err error) error {
+	if errors.Is(err, git.ErrRepoExists) || errors.Is(err, git.ErrInvalidRepoName) {
+		return err
+	}
+	slog.Error("could not run repo command", "error", err)
+	return ErrSystemMalfunction
+}
@@ -0,0 +1,35 @@
+package ssh
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"go.jolheiser.com/ugit/internal/git"
+)
+
+func TestRequiredAccess(t *testing.T) {
+	tt := []struct {
+		cmd      string
+		args     []string
+		required git.AccessLevel
+	}{
+		{cmd: "delete", required: git.AdminAccess},
+		{cmd: "rename", args: []string{"new"}, required: git.AdminAccess},
+		{cmd: "describe", required: git.ReadOnlyAccess},
+		{cmd: "describe", args: []string{"a", "repo"}, required: git.ReadWriteAccess},
+		{cmd: "tag", required: git.ReadOnlyAccess},
+		{cmd: "tag", args: []string{"go", "-old"}, required: git.ReadWriteAccess},
+		{cmd: "set-private", required: git.ReadOnlyAccess},
+		{cmd: "set-private", args: []string{"false"}, required: git.AdminAccess},
+		{cmd: "set-default-branch", required: git.ReadOnlyAccess},
+		{cmd: "set-default-branch", args: []string{"main"}, required: git.AdminAccess},
+	}
+	for _, tc := range tt {
+		required, ok := requiredAccess(tc.cmd, tc.args)
+		assert.True(t, ok, tc.cmd)
+		assert.Equal(t, tc.required, required, "%s %v", tc.cmd, tc.args)
+	}
+
+	_, ok := requiredAccess("unknown", nil)
+	assert.False(t, ok)
+}
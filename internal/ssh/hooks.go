@@ -0,0 +1,56 @@
+package ssh
+
+import (
+	"log/slog"
+
+	"github.com/charmbracelet/ssh"
+	"go.jolheiser.com/ugit/internal/git"
+)
+
+// CommandHooks is a Hooks implementation that authorizes using a Keyring,
+// runs the shared git.PostReceive after successful pushes, and runs shell commands after successful fetches.
+// Fetch commands are run in the background the same as push commands, see git.Repo.RunHook.
+type CommandHooks struct {
+	RepoDir       string
+	Keyring       Keyring
+	PostReceive   git.PostReceive
+	FetchCommands []string
+}
+
+var _ Hooks = (*CommandHooks)(nil)
+
+// AuthRepo implements Hooks
+func (c CommandHooks) AuthRepo(repo string, pk ssh.PublicKey) git.AccessLevel {
+	return c.Keyring.AuthRepo(c.RepoDir, repo, pk)
+}
+
+// Push implements Hooks
+func (c CommandHooks) Push(repo string, pk ssh.PublicKey, refs []git.RefUpdate) {
+	r, err := git.NewRepo(c.RepoDir, repo)
+	if err != nil {
+		slog.Error("could not open repo after push", "repo", repo, "error", err)
+		return
+	}
+	c.PostReceive.Run(r, c.user(pk), refs)
+}
+
+// Fetch implements Hooks
+func (c CommandHooks) Fetch(repo string, pk ssh.PublicKey) {
+	if len(c.FetchCommands) == 0 {
+		return
+	}
+	r, err := git.NewRepo(c.RepoDir, repo)
+	if err != nil {
+		slog.Error("could not open repo after fetch", "repo", repo, "error", err)
+		return
+	}
+	for _, cmd := range c.FetchCommands {
+		go r.RunHook(cmd, c.user(pk), "")
+	}
+}
+
+// user returns who a key belongs to, for hooks
+func (c CommandHooks) user(pk ssh.PublicKey) git.Pusher {
+	id, _ := c.Keyring.Lookup(pk)
+	return git.Pusher{Name: id.Name, Fingerprint: id.Fingerprint}
+}
@@ -0,0 +1,60 @@
+package ssh
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.jolheiser.com/ugit/internal/git"
+
+	"github.com/charmbracelet/ssh"
+)
+
+// lfsAuthenticateResponse tells git-lfs where the LFS API of a repo is and how to authenticate with it
+type lfsAuthenticateResponse struct {
+	Href      string            `json:"href"`
+	Header    map[string]string `json:"header"`
+	ExpiresIn int               `json:"expires_in"`
+}
+
+// lfsAuthenticate answers "git-lfs-authenticate <repo> <upload|download>" with a token for the HTTP LFS API
+func lfsAuthenticate(s ssh.Session, repoDir string, gh Hooks, lfs *git.LFSAuth, args []string) error {
+	if lfs == nil {
+		return errors.New("git lfs is not enabled")
+	}
+	if len(args) != 2 {
+		return errors.New("usage: git-lfs-authenticate <repo> <upload|download>")
+	}
+	name, operation := args[0], args[1]
+	if !validName(name) {
+		return ErrInvalidRepo
+	}
+
+	required := git.ReadOnlyAccess
+	switch operation {
+	case "download":
+	case "upload":
+		required = git.ReadWriteAccess
+	default:
+		return errors.New("operation must be upload or download")
+	}
+	access := gh.AuthRepo(name, s.PublicKey())
+	if access == git.NoAccess {
+		return ErrInvalidRepo
+	}
+	if access < required {
+		return ErrUnauthorized
+	}
+	repo, err := git.NewRepo(repoDir, name)
+	if err != nil {
+		return ErrInvalidRepo
+	}
+
+	// The token only grants what the operation needs
+	token, expiry := lfs.Token(repo.Name(), required)
+	return json.NewEncoder(s).Encode(lfsAuthenticateResponse{
+		Href:      lfs.Href(repo.Name()),
+		Header:    map[string]string{"Authorization": "Bearer " + token},
+		ExpiresIn: int(time.Until(expiry).Seconds()),
+	})
+}
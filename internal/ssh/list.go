@@ -0,0 +1,202 @@
+package ssh
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.jolheiser.com/ugit/internal/git"
+
+	"github.com/charmbracelet/ssh"
+)
+
+const listUsage = `usage: ls [--json] [--tag <tag>]... [--visibility public|private] [name-glob]`
+
+// listOptions filters and formats the repo list
+type listOptions struct {
+	json       bool
+	tags       []string
+	visibility string
+	glob       string
+}
+
+func parseListArgs(args []string) (listOptions, error) {
+	var opts listOptions
+	fs := flag.NewFlagSet("ls", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&opts.json, "json", false, "")
+	fs.Func("tag", "", func(tag string) error {
+		opts.tags = append(opts.tags, strings.ToLower(tag))
+		return nil
+	})
+	fs.Func("visibility", "", func(visibility string) error {
+		if visibility != "public" && visibility != "private" {
+			return errors.New("must be public or private")
+		}
+		opts.visibility = visibility
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		return opts, fmt.Errorf("%w\n%s", err, listUsage)
+	}
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		opts.glob = fs.Arg(0)
+		if _, err := path.Match(opts.glob, ""); err != nil {
+			return opts, fmt.Errorf("invalid name glob %q", opts.glob)
+		}
+	default:
+		return opts, errors.New(listUsage)
+	}
+	return opts, nil
+}
+
+// match returns whether a repo passes the filters
+func (o listOptions) match(repo *git.Repo) bool {
+	if o.glob != "" {
+		if ok, _ := path.Match(o.glob, repo.Name()); !ok {
+			return false
+		}
+	}
+	switch o.visibility {
+	case "public":
+		if repo.Private() {
+			return false
+		}
+	case "private":
+		if !repo.Private() {
+			return false
+		}
+	}
+	for _, tag := range o.tags {
+		if !repo.Meta.Tags.Contains(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// listedRepo is a repo in the JSON repo list
+type listedRepo struct {
+	Name          string     `json:"name"`
+	Description   string     `json:"description"`
+	Private       bool       `json:"private"`
+	Tags          []string   `json:"tags"`
+	CloneURL      string     `json:"clone_url"`
+	DefaultBranch string     `json:"default_branch"`
+	LastCommit    *time.Time `json:"last_commit"`
+	Size          int64      `json:"size"`
+}
+
+// listRepos writes the repos the session can read, either as a table or as JSON
+func listRepos(s ssh.Session, repoDir, cloneURL string, gh Hooks, opts listOptions) error {
+	names := repoNames(repoDir)
+
+	tw := tabwriter.NewWriter(s, 0, 0, 1, ' ', 0)
+	listed := make([]listedRepo, 0, len(names))
+	for _, name := range names {
+		if gh.AuthRepo(name, s.PublicKey()) < git.ReadOnlyAccess {
+			continue
+		}
+		repoURL := fmt.Sprintf("%s/%s.git", cloneURL, name)
+		repo, err := git.NewRepo(repoDir, name)
+
+		if opts.json {
+			if err != nil {
+				slog.Error("invalid repository", "repo", name, "error", err)
+				continue
+			}
+			if !opts.match(repo) {
+				continue
+			}
+			listed = append(listed, newListedRepo(repo, repoURL))
+			continue
+		}
+
+		if err == nil && !opts.match(repo) {
+			continue
+		}
+		visibility := "❓"
+		var mirrors string
+		if err == nil {
+			visibility = "🔓"
+			if repo.Private() {
+				visibility = "🔒"
+			}
+			mirrors = repo.PushMirrorSummary()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s", name, visibility, repoURL)
+		if mirrors != "" {
+			fmt.Fprintf(tw, "\t%s", mirrors)
+		}
+		fmt.Fprintln(tw)
+	}
+
+	if opts.json {
+		enc := json.NewEncoder(s)
+		enc.SetIndent("", "  ")
+		return enc.Encode(listed)
+	}
+	return tw.Flush()
+}
+
+func newListedRepo(repo *git.Repo, cloneURL string) listedRepo {
+	tags := repo.Meta.Tags.Slice()
+	if tags == nil {
+		tags = []string{}
+	}
+	defaultBranch, _ := repo.DefaultBranch()
+	listed := listedRepo{
+		Name:          repo.Name(),
+		Description:   repo.Meta.Description,
+		Private:       repo.Private(),
+		Tags:          tags,
+		CloneURL:      cloneURL,
+		DefaultBranch: defaultBranch,
+	}
+	if commit, err := repo.LastCommit(); err == nil {
+		listed.LastCommit = &commit.When
+	}
+	if size, err := repo.Size(); err == nil {
+		listed.Size = size
+	}
+	return listed
+}
+
+// repoNames returns the names of every repo, including those in namespaces, sorted by name
+func repoNames(repoDir string) []string {
+	var names []string
+	if err := git.WalkRepos(repoDir, func(name string) error {
+		names = append(names, name)
+		return nil
+	}); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Error("invalid repository", "error", err)
+	}
+	return names
+}
+
+// readableRepos returns the repos a public key can read, sorted by name
+func readableRepos(repoDir string, gh Hooks, pk ssh.PublicKey) []*git.Repo {
+	var repos []*git.Repo
+	for _, name := range repoNames(repoDir) {
+		if gh.AuthRepo(name, pk) < git.ReadOnlyAccess {
+			continue
+		}
+		repo, err := git.NewRepo(repoDir, name)
+		if err != nil {
+			slog.Error("invalid repository", "repo", name, "error", err)
+			continue
+		}
+		repos = append(repos, repo)
+	}
+	return repos
+}
@@ -0,0 +1,98 @@
+package ssh
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"go.jolheiser.com/ugit/internal/git"
+)
+
+func TestParseListArgs(t *testing.T) {
+	tt := []struct {
+		name string
+		args []string
+		opts listOptions
+		err  bool
+	}{
+		{name: "none"},
+		{name: "json", args: []string{"--json"}, opts: listOptions{json: true}},
+		{name: "tags", args: []string{"--tag", "Go", "-tag=cli"}, opts: listOptions{tags: []string{"go", "cli"}}},
+		{name: "public", args: []string{"--visibility", "public"}, opts: listOptions{visibility: "public"}},
+		{name: "private", args: []string{"--visibility=private"}, opts: listOptions{visibility: "private"}},
+		{name: "glob", args: []string{"--json", "u*"}, opts: listOptions{json: true, glob: "u*"}},
+		{name: "namespaced glob", args: []string{"group/*"}, opts: listOptions{glob: "group/*"}},
+		{name: "invalid visibility", args: []string{"--visibility", "internal"}, err: true},
+		{name: "unknown flag", args: []string{"--all"}, err: true},
+		{name: "missing tag", args: []string{"--tag"}, err: true},
+		{name: "invalid glob", args: []string{"[u"}, err: true},
+		{name: "multiple globs", args: []string{"u*", "g*"}, err: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			opts, err := parseListArgs(tc.args)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.opts, opts)
+		})
+	}
+}
+
+func TestListOptionsMatch(t *testing.T) {
+	tmp := t.TempDir()
+	newRepo := func(name string, private bool, tags ...string) *git.Repo {
+		t.Helper()
+		repo, err := git.CreateRepo(tmp, name)
+		assert.NoError(t, err)
+		repo.Meta.Private = private
+		for _, tag := range tags {
+			repo.Meta.Tags.Add(tag)
+		}
+		return repo
+	}
+	ugit := newRepo("ugit", false, "go", "cli")
+	secret := newRepo("secret", true, "go")
+	nested := newRepo("group/tool", false)
+
+	tt := []struct {
+		name  string
+		opts  listOptions
+		match []*git.Repo
+	}{
+		{name: "none", match: []*git.Repo{ugit, secret, nested}},
+		{name: "public", opts: listOptions{visibility: "public"}, match: []*git.Repo{ugit, nested}},
+		{name: "private", opts: listOptions{visibility: "private"}, match: []*git.Repo{secret}},
+		{name: "tag", opts: listOptions{tags: []string{"go"}}, match: []*git.Repo{ugit, secret}},
+		{name: "all tags", opts: listOptions{tags: []string{"go", "cli"}}, match: []*git.Repo{ugit}},
+		{name: "unknown tag", opts: listOptions{tags: []string{"rust"}}},
+		{name: "glob", opts: listOptions{glob: "*i*"}, match: []*git.Repo{ugit}},
+		{name: "glob stops at namespaces", opts: listOptions{glob: "*"}, match: []*git.Repo{ugit, secret}},
+		{name: "namespaced glob", opts: listOptions{glob: "group/*"}, match: []*git.Repo{nested}},
+		{name: "combined", opts: listOptions{glob: "s*", tags: []string{"go"}, visibility: "public"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var match []*git.Repo
+			for _, repo := range []*git.Repo{ugit, secret, nested} {
+				if tc.opts.match(repo) {
+					match = append(match, repo)
+				}
+			}
+			assert.Equal(t, tc.match, match)
+		})
+	}
+}
+
+func TestListedRepoTags(t *testing.T) {
+	repo, err := git.CreateRepo(t.TempDir(), "ugit")
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(newListedRepo(repo, "https://git.example.com/ugit.git"))
+	assert.NoError(t, err)
+	var listed map[string]any
+	assert.NoError(t, json.Unmarshal(b, &listed))
+	assert.Equal[any](t, []any{}, listed["tags"])
+}
@@ -3,6 +3,9 @@ package ssh
 import (
 	"fmt"
 	"log"
+	"os"
+
+	"go.jolheiser.com/ugit/internal/git"
 
 	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish"
@@ -12,20 +15,58 @@ import (
 // Settings holds the configuration for the SSH server
 type Settings struct {
 	AuthorizedKeys string
+	UserKeys       string
+	Admins         []string
 	CloneURL       string
 	Port           int
 	HostKey        string
 	RepoDir        string
+	PushHooks      []string
+	FetchHooks     []string
+	// Catalog is shared with the HTTP server so pushes over SSH show up on the index
+	Catalog *git.Catalog
+	// PushMirrors queues pushes to push mirrors, if set
+	PushMirrors *git.PushMirrorQueue
+	// Webhooks queues webhook deliveries, if set
+	Webhooks *git.WebhookQueue
+	// LFS hands out tokens for the HTTP LFS API, git-lfs-authenticate is refused if nil
+	LFS *git.LFSAuth
+	// Signing are the keys signatures are verified against for protected refs
+	Signing git.SigningKeys
 }
 
 // New creates a new SSH server.
 func New(settings Settings) (*ssh.Server, error) {
+	if _, err := os.Stat(settings.AuthorizedKeys); err != nil {
+		return nil, fmt.Errorf("could not find authorized keys: %w", err)
+	}
+	keyring := Keyring{
+		AuthorizedKeys: settings.AuthorizedKeys,
+		UserKeys:       settings.UserKeys,
+		Admins:         settings.Admins,
+	}
 	s, err := wish.NewServer(
-		wish.WithAuthorizedKeys(settings.AuthorizedKeys),
+		wish.WithPublicKeyAuth(func(ctx ssh.Context, pk ssh.PublicKey) bool {
+			id, ok := keyring.Lookup(pk)
+			if ok {
+				ctx.SetValue(identityCtxKey{}, id)
+			}
+			return ok
+		}),
 		wish.WithAddress(fmt.Sprintf(":%d", settings.Port)),
 		wish.WithHostKeyPath(settings.HostKey),
 		wish.WithMiddleware(
-			Middleware(settings.RepoDir, settings.CloneURL, settings.Port, hooks{}),
+			Middleware(settings.RepoDir, settings.CloneURL, settings.Port, CommandHooks{
+				RepoDir: settings.RepoDir,
+				Keyring: keyring,
+				PostReceive: git.PostReceive{
+					Catalog:     settings.Catalog,
+					PushMirrors: settings.PushMirrors,
+					Webhooks:    settings.Webhooks,
+					Commands:    settings.PushHooks,
+				},
+				FetchCommands: settings.FetchHooks,
+			}, settings.LFS, settings.Signing),
 			logging.MiddlewareWithLogger(DefaultLogger),
 		),
 	)
@@ -36,11 +77,6 @@ func New(settings Settings) (*ssh.Server, error) {
 	return s, nil
 }
 
-type hooks struct{}
-
-func (a hooks) Push(_ string, _ ssh.PublicKey)  {}
-func (a hooks) Fetch(_ string, _ ssh.PublicKey) {}
-
 var (
 	DefaultLogger logging.Logger = log.Default()
 	NoopLogger    logging.Logger = noopLogger{}
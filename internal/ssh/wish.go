@@ -4,17 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io/fs"
 	"log/slog"
-	"os"
 	"path/filepath"
 	"strings"
-	"text/tabwriter"
 
 	"go.jolheiser.com/ugit/internal/git"
+	"go.jolheiser.com/ugit/internal/tui"
 
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish"
+	bm "github.com/charmbracelet/wish/bubbletea"
 )
 
 // ErrSystemMalfunction represents a general system error returned to clients.
@@ -23,18 +23,27 @@ var ErrSystemMalfunction = errors.New("something went wrong")
 // ErrInvalidRepo represents an attempt to access a non-existent repo.
 var ErrInvalidRepo = errors.New("invalid repo")
 
+// ErrInvalidNamespace represents an attempt to access a non-existent namespace.
+var ErrInvalidNamespace = errors.New("invalid namespace")
+
+// ErrUnauthorized represents an attempt to push to a repo without write access.
+var ErrUnauthorized = errors.New("you are not authorized to do this")
+
 // Hooks is an interface that allows for custom authorization
 // implementations and post push/fetch notifications. Prior to git access,
 // AuthRepo will be called with the ssh.Session public key and the repo name.
 // Implementers return the appropriate AccessLevel.
+// Push receives the refs that were updated by the push.
 type Hooks interface {
-	Push(string, ssh.PublicKey)
+	AuthRepo(string, ssh.PublicKey) git.AccessLevel
+	Push(string, ssh.PublicKey, []git.RefUpdate)
 	Fetch(string, ssh.PublicKey)
 }
 
 // Session wraps sn ssh.Session to implement git.ReadWriteContexter
 type Session struct {
-	s ssh.Session
+	s   ssh.Session
+	ctx context.Context
 }
 
 // Read implements io.Reader
@@ -54,6 +63,9 @@ func (s Session) Close() error {
 
 // Context returns an interface context.Context
 func (s Session) Context() context.Context {
+	if s.ctx != nil {
+		return s.ctx
+	}
 	return s.s.Context()
 }
 
@@ -62,108 +74,193 @@ func (s Session) Context() context.Context {
 // checked for access on a per repo basis for a ssh.Session public key.
 // Hooks.Push and Hooks.Fetch will be called on successful completion of
 // their commands.
-func Middleware(repoDir string, cloneURL string, port int, gh Hooks) wish.Middleware {
+func Middleware(repoDir string, cloneURL string, port int, gh Hooks, lfs *git.LFSAuth, signing git.SigningKeys) wish.Middleware {
 	return func(sh ssh.Handler) ssh.Handler {
 		return func(s ssh.Session) {
-			sess := Session{s: s}
+			sess := Session{
+				s:   s,
+				ctx: git.WithPusher(s.Context(), pusher(s)),
+			}
 			cmd := s.Command()
 
+			// Repo management
+			if len(cmd) > 0 && cmd[0] == "repo" {
+				if err := repoCommand(s, repoDir, cloneURL, gh, cmd[1:]); err != nil {
+					wish.Fatalln(s, err)
+				}
+				return
+			}
+			if len(cmd) > 0 && cmd[0] == "namespace" {
+				if err := namespaceCommand(s, repoDir, gh, cmd[1:]); err != nil {
+					wish.Fatalln(s, err)
+				}
+				return
+			}
+
+			// Git LFS, objects are transferred over HTTP
+			if len(cmd) > 0 && cmd[0] == "git-lfs-authenticate" {
+				if err := lfsAuthenticate(s, repoDir, gh, lfs, cmd[1:]); err != nil {
+					wish.Fatalln(s, err)
+				}
+				return
+			}
+			// git-lfs falls back to git-lfs-authenticate when the SSH transfer protocol fails
+			if len(cmd) > 0 && cmd[0] == "git-lfs-transfer" {
+				wish.Fatalln(s, "git-lfs-transfer is not supported, use git-lfs-authenticate")
+				return
+			}
+
 			// Git operations
 			if len(cmd) == 2 {
 				gc := cmd[0]
-				// repo should be in the form of "repo.git" or "user/repo.git"
+				// repo should be in the form of "repo.git" or "namespace/repo.git"
 				repo := strings.TrimSuffix(strings.TrimPrefix(cmd[1], "/"), "/")
 				repo = filepath.Clean(repo)
-				if n := strings.Count(repo, "/"); n > 1 {
+				if !validName(repo) {
 					Fatal(s, ErrInvalidRepo)
 					return
 				}
 				pk := s.PublicKey()
+				access := gh.AuthRepo(repo, pk)
 				switch gc {
 				case "git-receive-pack":
-					if err := gitPack(sess, gc, repoDir, repo); err != nil {
+					exists, err := git.PathExists(filepath.Join(repoDir, repoPath(repo)))
+					if err != nil {
+						Fatal(s, ErrSystemMalfunction)
+						return
+					}
+					switch {
+					case !exists && access < git.AdminAccess:
+						Fatal(s, ErrUnauthorized)
+						return
+					case !exists:
+						if err := git.ValidateNewRepo(repoDir, repo); err != nil {
+							Fatal(s, commandError(err))
+							return
+						}
+					case access == git.NoAccess:
+						Fatal(s, ErrInvalidRepo)
+						return
+					case access < git.ReadWriteAccess:
+						Fatal(s, ErrUnauthorized)
+						return
+					}
+					p := pusher(s)
+					p.Access = access
+					sess.ctx = git.WithSigningKeys(git.WithPusher(s.Context(), p), signing)
+					refs, err := gitPack(sess, gc, repoDir, repo)
+					if err != nil {
+						slog.Error("unknown git error", "error", err)
 						Fatal(s, ErrSystemMalfunction)
+						return
 					}
-					gh.Push(repo, pk)
+					gh.Push(repo, pk, refs)
 					return
 				case "git-upload-archive", "git-upload-pack":
-					if err := gitPack(sess, gc, repoDir, repo); err != nil {
+					if access < git.ReadOnlyAccess {
+						Fatal(s, ErrInvalidRepo)
+						return
+					}
+					if _, err := gitPack(sess, gc, repoDir, repo); err != nil {
 						if errors.Is(err, ErrInvalidRepo) {
 							Fatal(s, ErrInvalidRepo)
+							return
 						}
 						slog.Error("unknown git error", "error", err)
 						Fatal(s, ErrSystemMalfunction)
+						return
 					}
 					gh.Fetch(repo, pk)
 					return
 				}
 			}
 
+			// Interactive sessions get the TUI
+			if len(cmd) == 0 {
+				if _, _, ok := s.Pty(); ok {
+					bm.Middleware(func(s ssh.Session) (tea.Model, []tea.ProgramOption) {
+						m := tui.New(readableRepos(repoDir, gh, s.PublicKey()), cloneURL, bm.MakeRenderer(s))
+						return m, []tea.ProgramOption{tea.WithAltScreen()}
+					})(sh)(s)
+					return
+				}
+			}
+
 			// Repo list
 			if len(cmd) == 0 {
-				des, err := os.ReadDir(repoDir)
-				if err != nil && err != fs.ErrNotExist {
-					slog.Error("invalid repository", "error", err)
+				if err := listRepos(s, repoDir, cloneURL, gh, listOptions{}); err != nil {
+					slog.Error("could not list repos", "error", err)
 				}
-				tw := tabwriter.NewWriter(s, 0, 0, 1, ' ', 0)
-				for _, de := range des {
-					if filepath.Ext(de.Name()) != ".git" {
-						continue
-					}
-					repo, err := git.NewRepo(repoDir, de.Name())
-					visibility := "❓"
-					if err == nil {
-						visibility = "🔓"
-						if repo.Meta.Private {
-							visibility = "🔒"
-						}
-					}
-					fmt.Fprintf(tw, "%[1]s\t%[3]s\t%[2]s/%[1]s.git\n", strings.TrimSuffix(de.Name(), ".git"), cloneURL, visibility)
+			}
+			if len(cmd) > 0 && cmd[0] == "ls" {
+				opts, err := parseListArgs(cmd[1:])
+				if err != nil {
+					wish.Fatalln(s, err)
+					return
+				}
+				if err := listRepos(s, repoDir, cloneURL, gh, opts); err != nil {
+					slog.Error("could not list repos", "error", err)
 				}
-				tw.Flush()
+				return
 			}
 			sh(s)
 		}
 	}
 }
 
-func gitPack(s Session, gitCmd string, repoDir string, repoName string) error {
+// repoPath returns the on-disk name of a repo, which always ends in .git
+func repoPath(repo string) string {
+	if !strings.HasSuffix(repo, ".git") {
+		repo += ".git"
+	}
+	return repo
+}
+
+// gitPack runs the git command for a repo, returning the refs updated by a git-receive-pack
+func gitPack(s Session, gitCmd string, repoDir string, repoName string) ([]git.RefUpdate, error) {
+	repoName = repoPath(repoName)
 	rp := filepath.Join(repoDir, repoName)
-	protocol, err := git.NewProtocol(rp)
+	protocol, err := git.NewProtocol(repoDir, repoName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	switch gitCmd {
 	case "git-upload-pack":
 		exists, err := git.PathExists(rp)
 		if !exists {
-			return ErrInvalidRepo
+			return nil, ErrInvalidRepo
 		}
 		if err != nil {
-			return err
+			return nil, err
 		}
-		return protocol.SSHUploadPack(s)
+		return nil, protocol.SSHUploadPack(s)
 	case "git-receive-pack":
 		err := git.EnsureRepo(repoDir, repoName)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		repo, err := git.NewRepo(repoDir, repoName)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		before, err := repo.Refs()
+		if err != nil {
+			return nil, err
 		}
 		err = protocol.SSHReceivePack(s, repo)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if err := repo.AfterReceive(); err != nil {
+			return nil, err
 		}
-		_, err = repo.DefaultBranch()
+		after, err := repo.Refs()
 		if err != nil {
-			return err
+			return nil, err
 		}
-		// Needed for git dumb http server
-		return git.UpdateServerInfo(rp)
+		return git.DiffRefs(before, after), nil
 	default:
-		return fmt.Errorf("unknown git command: %s", gitCmd)
+		return nil, fmt.Errorf("unknown git command: %s", gitCmd)
 	}
 }
 
@@ -0,0 +1,112 @@
+package tui
+
+import (
+	"fmt"
+
+	"go.jolheiser.com/ugit/internal/git"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// item is a list entry, open is run in the background when it is selected
+type item struct {
+	title string
+	desc  string
+	open  func() tea.Msg
+}
+
+func (i item) Title() string       { return i.title }
+func (i item) Description() string { return i.desc }
+func (i item) FilterValue() string { return i.title }
+
+var (
+	openKey = key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "open"))
+	backKey = key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "back"))
+	quitKey = key.NewBinding(key.WithKeys("q"), key.WithHelp("q", "quit"))
+)
+
+// listView is a filterable list of items
+type listView struct {
+	title string
+	list  list.Model
+}
+
+func newListView(title string, items []item, st styles) *listView {
+	listItems := make([]list.Item, 0, len(items))
+	for _, i := range items {
+		listItems = append(listItems, i)
+	}
+
+	delegate := list.NewDefaultDelegate()
+	delegate.Styles = st.item
+	l := list.New(listItems, delegate, 0, 0)
+	l.SetShowTitle(false)
+	l.DisableQuitKeybindings()
+	l.Styles = st.list
+	l.FilterInput.PromptStyle = st.list.FilterPrompt
+	l.FilterInput.Cursor.Style = st.list.FilterCursor
+	l.Paginator.ActiveDot = st.list.ActivePaginationDot.String()
+	l.Paginator.InactiveDot = st.list.InactivePaginationDot.String()
+	rebind(&l.Help.Styles, st.renderer)
+	l.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{openKey, backKey, quitKey}
+	}
+	return &listView{
+		title: title,
+		list:  l,
+	}
+}
+
+func (l *listView) Update(msg tea.Msg) (view, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok && key.Matches(msg, openKey) && l.list.FilterState() != list.Filtering {
+		if i, ok := l.list.SelectedItem().(item); ok && i.open != nil {
+			return l, i.open
+		}
+		return l, nil
+	}
+	var cmd tea.Cmd
+	l.list, cmd = l.list.Update(msg)
+	return l, cmd
+}
+
+func (l *listView) View() string {
+	return l.list.View()
+}
+
+func (l *listView) SetSize(width, height int) {
+	l.list.SetSize(width, height)
+}
+
+func (l *listView) Title() string {
+	return l.title
+}
+
+func (l *listView) Capturing() bool {
+	return l.list.FilterState() != list.Unfiltered
+}
+
+func newRepoListView(repos []*git.Repo, cloneURL string, st styles) view {
+	items := make([]item, 0, len(repos))
+	for _, repo := range repos {
+		title := repo.Name()
+		if repo.Private() {
+			title += " 🔒"
+		}
+		desc := repo.Meta.Description
+		if desc == "" {
+			desc = fmt.Sprintf("%s/%s.git", cloneURL, repo.Name())
+		}
+		items = append(items, item{
+			title: title,
+			desc:  desc,
+			open: func() tea.Msg {
+				return push(newRepoView(repo, cloneURL, st))
+			},
+		})
+	}
+	l := newListView("repos", items, st)
+	l.list.SetStatusBarItemName("repo", "repos")
+	return l
+}
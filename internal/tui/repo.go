@@ -0,0 +1,243 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"go.jolheiser.com/ugit/internal/git"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/dustin/go-humanize"
+)
+
+// logLimit is how many commits the log tab shows
+const logLimit = 200
+
+var tabNames = []string{"files", "log", "branches", "tags", "search"}
+
+// refMsg switches the repo view to another branch or tag
+type refMsg struct {
+	ref string
+}
+
+// repoView shows a repo as a set of tabs, each loaded when first shown
+type repoView struct {
+	repo     *git.Repo
+	cloneURL string
+	ref      string
+	styles   styles
+	tabs     []view
+	active   int
+	width    int
+	height   int
+}
+
+func newRepoView(repo *git.Repo, cloneURL string, st styles) (view, error) {
+	ref, err := repo.DefaultBranch()
+	if err != nil {
+		return nil, err
+	}
+	r := &repoView{
+		repo:     repo,
+		cloneURL: cloneURL,
+		ref:      ref,
+		styles:   st,
+		tabs:     make([]view, len(tabNames)),
+	}
+	r.load()
+	return r, nil
+}
+
+// load creates the active tab if needed, showing any error in place of the tab
+func (r *repoView) load() {
+	if r.tabs[r.active] != nil {
+		return
+	}
+	var v view
+	var err error
+	switch tabNames[r.active] {
+	case "files":
+		v, err = newTreeView(r.repo, r.ref, "", r.styles)
+	case "log":
+		v, err = newLogView(r.repo, r.ref, r.styles)
+	case "branches":
+		v, err = newBranchesView(r.repo, r.styles)
+	case "tags":
+		v, err = newTagsView(r.repo, r.styles)
+	case "search":
+		v = newSearchView(r.repo, r.ref, r.styles)
+	}
+	if err != nil {
+		v = newTextView(tabNames[r.active], r.styles.err.Render(err.Error()), r.styles)
+	}
+	v.SetSize(r.tabSize())
+	r.tabs[r.active] = v
+}
+
+func (r *repoView) Update(msg tea.Msg) (view, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if !r.Capturing() {
+			switch msg.String() {
+			case "tab":
+				r.active = (r.active + 1) % len(r.tabs)
+				r.load()
+				return r, nil
+			case "shift+tab":
+				r.active = (r.active + len(r.tabs) - 1) % len(r.tabs)
+				r.load()
+				return r, nil
+			}
+		}
+	case refMsg:
+		r.ref = msg.ref
+		r.tabs = make([]view, len(tabNames))
+		r.active = 0
+		r.load()
+		return r, nil
+	}
+
+	var cmd tea.Cmd
+	r.tabs[r.active], cmd = r.tabs[r.active].Update(msg)
+	return r, cmd
+}
+
+func (r *repoView) View() string {
+	tabs := make([]string, 0, len(tabNames)+1)
+	for idx, name := range tabNames {
+		style := r.styles.tab
+		if idx == r.active {
+			style = r.styles.activeTab
+		}
+		tabs = append(tabs, style.Render(name))
+	}
+	tabs = append(tabs, r.styles.faint.Render(fmt.Sprintf("  %s/%s.git", r.cloneURL, r.repo.Name())))
+	bar := lipgloss.JoinHorizontal(lipgloss.Top, tabs...)
+	return lipgloss.JoinVertical(lipgloss.Left, bar, "", r.tabs[r.active].View())
+}
+
+func (r *repoView) SetSize(width, height int) {
+	r.width, r.height = width, height
+	for _, tab := range r.tabs {
+		if tab != nil {
+			tab.SetSize(r.tabSize())
+		}
+	}
+}
+
+// tabSize is the size left for a tab after the tab bar
+func (r *repoView) tabSize() (int, int) {
+	return r.width, max(r.height-2, 0)
+}
+
+func (r *repoView) Title() string {
+	return fmt.Sprintf("%s@%s", r.repo.Name(), r.ref)
+}
+
+func (r *repoView) Capturing() bool {
+	return r.tabs[r.active].Capturing()
+}
+
+func newTreeView(repo *git.Repo, ref, path string, st styles) (view, error) {
+	fis, err := repo.Dir(ref, path)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]item, 0, len(fis))
+	for _, fi := range fis {
+		i := item{
+			title: fi.Name(),
+			desc:  fmt.Sprintf("%s %s", fi.Mode, fi.Size),
+		}
+		if fi.IsDir {
+			i.title += "/"
+			i.open = func() tea.Msg {
+				return push(newTreeView(repo, ref, fi.Path, st))
+			}
+		} else {
+			i.open = func() tea.Msg {
+				return push(newFileView(repo, ref, fi.Path, 0, st))
+			}
+		}
+		items = append(items, i)
+	}
+
+	title := path
+	if title == "" {
+		title = "files"
+	}
+	l := newListView(title, items, st)
+	l.list.SetStatusBarItemName("entry", "entries")
+	return l, nil
+}
+
+func newLogView(repo *git.Repo, ref string, st styles) (view, error) {
+	commits, _, err := repo.Commits(ref, git.LogOptions{Limit: logLimit})
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]item, 0, len(commits))
+	for _, commit := range commits {
+		items = append(items, item{
+			title: commit.Summary(),
+			desc:  fmt.Sprintf("%s %s %s", commit.Short(), commit.Author, humanize.Time(commit.When)),
+			open: func() tea.Msg {
+				return push(newCommitView(repo, commit.SHA, st))
+			},
+		})
+	}
+	l := newListView("log", items, st)
+	l.list.SetStatusBarItemName("commit", "commits")
+	return l, nil
+}
+
+func newBranchesView(repo *git.Repo, st styles) (view, error) {
+	branches, err := repo.Branches()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]item, 0, len(branches))
+	for _, branch := range branches {
+		var desc string
+		if commit, err := repo.GetCommitFromRef(branch); err == nil {
+			summary, _, _ := strings.Cut(commit.Message, "\n")
+			desc = fmt.Sprintf("%s %s", summary, humanize.Time(commit.Author.When))
+		}
+		items = append(items, item{
+			title: branch,
+			desc:  desc,
+			open: func() tea.Msg {
+				return refMsg{ref: branch}
+			},
+		})
+	}
+	l := newListView("branches", items, st)
+	l.list.SetStatusBarItemName("branch", "branches")
+	return l, nil
+}
+
+func newTagsView(repo *git.Repo, st styles) (view, error) {
+	tags, err := repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]item, 0, len(tags))
+	for _, tag := range tags {
+		annotation, _, _ := strings.Cut(tag.Annotation, "\n")
+		items = append(items, item{
+			title: tag.Name,
+			desc:  strings.TrimSpace(fmt.Sprintf("%s %s", humanize.Time(tag.When), annotation)),
+			open: func() tea.Msg {
+				return refMsg{ref: tag.Name}
+			},
+		})
+	}
+	l := newListView("tags", items, st)
+	l.list.SetStatusBarItemName("tag", "tags")
+	return l, nil
+}
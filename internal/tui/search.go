@@ -0,0 +1,146 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"go.jolheiser.com/ugit/internal/git"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// searchLimit is how many results a search shows
+const searchLimit = 100
+
+// searchView greps a repo, the input is focused until a search is run or esc is pressed, and refocused with /
+type searchView struct {
+	repo    *git.Repo
+	ref     string
+	styles  styles
+	input   textinput.Model
+	results *listView
+	summary string
+	width   int
+	height  int
+}
+
+func newSearchView(repo *git.Repo, ref string, st styles) *searchView {
+	input := textinput.New()
+	input.Prompt = "/ "
+	input.Placeholder = "pattern, e.g. path:*.go func main"
+	input.PromptStyle = st.activeTab.UnsetPadding().UnsetUnderline()
+	input.PlaceholderStyle = st.faint
+	input.Cursor.Style = st.activeTab.UnsetPadding().UnsetUnderline()
+	input.Focus()
+	return &searchView{
+		repo:   repo,
+		ref:    ref,
+		styles: st,
+		input:  input,
+	}
+}
+
+func (s *searchView) Update(msg tea.Msg) (view, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case s.input.Focused() && msg.String() == "enter":
+			s.search()
+			return s, nil
+		case s.input.Focused() && msg.String() == "esc":
+			s.input.Blur()
+			return s, nil
+		case !s.input.Focused() && msg.String() == "/":
+			return s, s.input.Focus()
+		}
+	}
+
+	var cmd tea.Cmd
+	if s.input.Focused() {
+		s.input, cmd = s.input.Update(msg)
+		return s, cmd
+	}
+	if s.results != nil {
+		_, cmd = s.results.Update(msg)
+	}
+	return s, cmd
+}
+
+func (s *searchView) search() {
+	query := strings.TrimSpace(s.input.Value())
+	if query == "" {
+		return
+	}
+	// Search the ref being browsed unless the query asks for another
+	ref := s.ref
+	if search, err := git.ParseSearch(query); err == nil && search.Ref != "" {
+		ref = search.Ref
+	} else {
+		query = fmt.Sprintf("ref:%s %s", ref, query)
+	}
+
+	results, more, err := s.repo.Grep(query, git.GrepOptions{Limit: searchLimit})
+	if err != nil {
+		s.results, s.summary = nil, s.styles.err.Render(err.Error())
+		return
+	}
+
+	items := make([]item, 0, len(results))
+	for _, result := range results {
+		lines := strings.Split(result.Content, "\n")
+		match := ""
+		if idx := result.Line - result.StartLine; idx >= 0 && idx < len(lines) {
+			match = strings.TrimSpace(lines[idx])
+		}
+		items = append(items, item{
+			title: fmt.Sprintf("%s:%d", result.File, result.Line),
+			desc:  match,
+			open: func() tea.Msg {
+				return push(newFileView(s.repo, ref, result.File, result.Line, s.styles))
+			},
+		})
+	}
+
+	s.summary = fmt.Sprintf("%d results", len(results))
+	if more {
+		s.summary = fmt.Sprintf("%d+ results", len(results))
+	}
+	s.results = newListView("results", items, s.styles)
+	s.results.list.SetFilteringEnabled(false)
+	s.results.list.SetShowStatusBar(false)
+	s.results.SetSize(s.resultsSize())
+	s.input.Blur()
+}
+
+func (s *searchView) View() string {
+	parts := []string{s.input.View()}
+	if s.summary != "" {
+		parts = append(parts, s.styles.faint.Render(s.summary))
+	}
+	if s.results != nil {
+		parts = append(parts, "", s.results.View())
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, parts...)
+}
+
+func (s *searchView) SetSize(width, height int) {
+	s.width, s.height = width, height
+	s.input.Width = max(width-4, 0)
+	if s.results != nil {
+		s.results.SetSize(s.resultsSize())
+	}
+}
+
+// resultsSize is the size left for results after the input and summary
+func (s *searchView) resultsSize() (int, int) {
+	return s.width, max(s.height-3, 0)
+}
+
+func (s *searchView) Title() string {
+	return "search"
+}
+
+func (s *searchView) Capturing() bool {
+	return s.input.Focused()
+}
@@ -0,0 +1,118 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.jolheiser.com/ugit/internal/git"
+	"go.jolheiser.com/ugit/internal/html/markup"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// textView is scrollable text, such as a file or a commit
+type textView struct {
+	title    string
+	styles   styles
+	viewport viewport.Model
+	// line is scrolled to once the view is sized, 1-indexed
+	line int
+}
+
+func newTextView(title, content string, st styles) *textView {
+	vp := viewport.New(0, 0)
+	vp.SetContent(content)
+	return &textView{
+		title:    title,
+		styles:   st,
+		viewport: vp,
+	}
+}
+
+func (t *textView) Update(msg tea.Msg) (view, tea.Cmd) {
+	var cmd tea.Cmd
+	t.viewport, cmd = t.viewport.Update(msg)
+	return t, cmd
+}
+
+func (t *textView) View() string {
+	footer := t.styles.faint.Render(fmt.Sprintf(" ↑/↓ scroll • esc back • q quit • %3.f%%", t.viewport.ScrollPercent()*100))
+	return lipgloss.JoinVertical(lipgloss.Left, t.viewport.View(), footer)
+}
+
+func (t *textView) SetSize(width, height int) {
+	t.viewport.Width = width
+	t.viewport.Height = max(height-1, 0)
+	if t.line > 0 && height > 0 {
+		t.viewport.SetYOffset(t.line - 1 - t.viewport.Height/2)
+		t.line = 0
+	}
+}
+
+func (t *textView) Title() string {
+	return t.title
+}
+
+func (t *textView) Capturing() bool {
+	return false
+}
+
+// numbered highlights content and prefixes each line with its line number
+func numbered(content, fileName string, st styles) (string, error) {
+	lines, err := markup.Terminal([]byte(strings.ReplaceAll(content, "\t", "    ")), fileName)
+	if err != nil {
+		return "", err
+	}
+	width := len(strconv.Itoa(len(lines)))
+	for idx, line := range lines {
+		lines[idx] = st.lineNumber.Render(fmt.Sprintf("%*d ", width, idx+1)) + line
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// newFileView shows a highlighted file, scrolled to line if it is set
+func newFileView(repo *git.Repo, ref, path string, line int, st styles) (view, error) {
+	content, err := repo.FileContent(ref, path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ContainsRune(content, 0) {
+		return newTextView(path, st.faint.Render("binary file not shown"), st), nil
+	}
+
+	code, err := numbered(content, filepath.Base(path), st)
+	if err != nil {
+		return nil, err
+	}
+	t := newTextView(path, code, st)
+	t.line = line
+	return t, nil
+}
+
+func newCommitView(repo *git.Repo, sha string, st styles) (view, error) {
+	commit, err := repo.Commit(sha)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "commit %s\n", commit.SHA)
+	fmt.Fprintf(&b, "Author: %s <%s>\n", commit.Author, commit.Email)
+	fmt.Fprintf(&b, "Date:   %s\n\n", commit.When.Format(time.RFC1123Z))
+	for line := range strings.SplitSeq(strings.TrimSpace(commit.Message), "\n") {
+		fmt.Fprintf(&b, "    %s\n", line)
+	}
+	fmt.Fprintf(&b, "\n %d files changed, %d insertions(+), %d deletions(-)\n\n", commit.Stats.Changed, commit.Stats.Additions, commit.Stats.Deletions)
+
+	patch, err := markup.Terminal([]byte(strings.ReplaceAll(commit.Patch, "\t", "    ")), "commit.diff")
+	if err != nil {
+		return nil, err
+	}
+	b.WriteString(strings.Join(patch, "\n"))
+	return newTextView(commit.Short(), b.String(), st), nil
+}
@@ -0,0 +1,180 @@
+// Package tui is an interactive terminal UI for browsing repos, served over SSH
+package tui
+
+import (
+	"reflect"
+	"strings"
+
+	"go.jolheiser.com/ugit/internal/git"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// view is a single screen, views are stacked as the user navigates and popped with esc
+type view interface {
+	Update(msg tea.Msg) (view, tea.Cmd)
+	View() string
+	SetSize(width, height int)
+	// Title is the view's part of the breadcrumb shown in the header
+	Title() string
+	// Capturing returns whether the view is taking text input, in which case q and esc are passed through
+	Capturing() bool
+}
+
+// pushMsg opens a view on top of the current one
+type pushMsg struct {
+	view view
+}
+
+// errMsg shows an error in the status line until the next key press
+type errMsg struct {
+	err error
+}
+
+func push(v view, err error) tea.Msg {
+	if err != nil {
+		return errMsg{err: err}
+	}
+	return pushMsg{view: v}
+}
+
+// Model is the root of the UI
+type Model struct {
+	styles styles
+	stack  []view
+	width  int
+	height int
+	err    error
+}
+
+// New returns a Model listing repos
+// The renderer should be made for the session so colors match the client's terminal
+func New(repos []*git.Repo, cloneURL string, renderer *lipgloss.Renderer) Model {
+	st := newStyles(renderer)
+	return Model{
+		styles: st,
+		stack:  []view{newRepoListView(repos, cloneURL, st)},
+	}
+}
+
+// Init implements [tea.Model]
+func (m Model) Init() tea.Cmd {
+	return nil
+}
+
+// Update implements [tea.Model]
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		for _, v := range m.stack {
+			v.SetSize(m.contentSize())
+		}
+		return m, nil
+	case tea.KeyMsg:
+		m.err = nil
+		top := m.top()
+		switch msg.String() {
+		case "ctrl+c":
+			return m, tea.Quit
+		case "q":
+			if !top.Capturing() {
+				return m, tea.Quit
+			}
+		case "esc":
+			if !top.Capturing() && len(m.stack) > 1 {
+				m.stack = m.stack[:len(m.stack)-1]
+				return m, nil
+			}
+		}
+	case pushMsg:
+		msg.view.SetSize(m.contentSize())
+		m.stack = append(m.stack, msg.view)
+		return m, nil
+	case errMsg:
+		m.err = msg.err
+		return m, nil
+	}
+
+	v, cmd := m.top().Update(msg)
+	m.stack[len(m.stack)-1] = v
+	return m, cmd
+}
+
+// View implements [tea.Model]
+func (m Model) View() string {
+	titles := make([]string, 0, len(m.stack)+1)
+	titles = append(titles, "µgit")
+	for _, v := range m.stack {
+		titles = append(titles, v.Title())
+	}
+	header := m.styles.header.Render(strings.Join(titles, " › "))
+
+	var status string
+	if m.err != nil {
+		status = m.styles.err.Render(m.err.Error())
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, header, m.top().View(), status)
+}
+
+func (m Model) top() view {
+	return m.stack[len(m.stack)-1]
+}
+
+// contentSize is the size left for a view after the header and status lines
+func (m Model) contentSize() (int, int) {
+	return m.width, max(m.height-2, 0)
+}
+
+type styles struct {
+	renderer   *lipgloss.Renderer
+	header     lipgloss.Style
+	err        lipgloss.Style
+	tab        lipgloss.Style
+	activeTab  lipgloss.Style
+	faint      lipgloss.Style
+	lineNumber lipgloss.Style
+	item       list.DefaultItemStyles
+	list       list.Styles
+}
+
+var (
+	mauve   = lipgloss.AdaptiveColor{Light: "#8839ef", Dark: "#cba6f7"}
+	red     = lipgloss.AdaptiveColor{Light: "#d20f39", Dark: "#f38ba8"}
+	text    = lipgloss.AdaptiveColor{Light: "#4c4f69", Dark: "#cdd6f4"}
+	subtext = lipgloss.AdaptiveColor{Light: "#6c6f85", Dark: "#a6adc8"}
+	overlay = lipgloss.AdaptiveColor{Light: "#9ca0b0", Dark: "#6c7086"}
+)
+
+func newStyles(r *lipgloss.Renderer) styles {
+	st := styles{
+		renderer:   r,
+		header:     r.NewStyle().Foreground(mauve).Bold(true).PaddingLeft(1),
+		err:        r.NewStyle().Foreground(red).PaddingLeft(1),
+		tab:        r.NewStyle().Foreground(subtext).Padding(0, 1),
+		activeTab:  r.NewStyle().Foreground(mauve).Bold(true).Underline(true).Padding(0, 1),
+		faint:      r.NewStyle().Foreground(overlay),
+		lineNumber: r.NewStyle().Foreground(overlay),
+		item:       list.NewDefaultItemStyles(),
+		list:       list.DefaultStyles(),
+	}
+	rebind(&st.item, r)
+	rebind(&st.list, r)
+	st.item.NormalTitle = st.item.NormalTitle.Foreground(text)
+	st.item.SelectedTitle = st.item.SelectedTitle.Foreground(mauve).BorderForeground(mauve)
+	st.item.SelectedDesc = st.item.SelectedDesc.Foreground(subtext).BorderForeground(mauve)
+	return st
+}
+
+// rebind points every lipgloss.Style field of a struct at a renderer
+// The bubbles defaults use the global renderer, which describes the server's terminal rather than the client's
+func rebind[T any](s *T, r *lipgloss.Renderer) {
+	v := reflect.ValueOf(s).Elem()
+	for i := range v.NumField() {
+		if style, ok := v.Field(i).Interface().(lipgloss.Style); ok {
+			v.Field(i).Set(reflect.ValueOf(style.Renderer(r)))
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package tui
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"go.jolheiser.com/ugit/internal/git"
+)
+
+// newTestModel returns a sized Model listing two repos, "alpha" and "beta",
+// beta has a commit adding main.go on main and a tag v1 on an earlier commit adding README.md
+func newTestModel(t *testing.T) Model {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is required")
+	}
+	tmp := t.TempDir()
+	repoDir := filepath.Join(tmp, "repos")
+	clientDir := filepath.Join(tmp, "client")
+	assert.NoError(t, os.MkdirAll(clientDir, os.ModePerm))
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=ugit", "-c", "user.email=ugit@example.com"}, args...)...)
+		cmd.Dir = clientDir
+		out, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(out))
+	}
+	run("init", "-q", "-b", "main")
+	assert.NoError(t, os.WriteFile(filepath.Join(clientDir, "README.md"), []byte("# beta\n"), 0o644))
+	run("add", ".")
+	run("commit", "-q", "-m", "first")
+	run("tag", "v1")
+	assert.NoError(t, os.WriteFile(filepath.Join(clientDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o644))
+	run("add", ".")
+	run("commit", "-q", "-m", "second")
+
+	var repos []*git.Repo
+	for _, name := range []string{"alpha", "beta"} {
+		repo, err := git.CreateRepo(repoDir, name)
+		assert.NoError(t, err)
+		run("push", "-q", repo.Path(), "main", "v1")
+		repos = append(repos, repo)
+	}
+
+	m := New(repos, "https://git.example.com", lipgloss.NewRenderer(io.Discard))
+	return update(t, m, tea.WindowSizeMsg{Width: 120, Height: 40})
+}
+
+// update sends msgs to a Model, ignoring any commands
+func update(t *testing.T, m Model, msgs ...tea.Msg) Model {
+	t.Helper()
+	for _, msg := range msgs {
+		model, _ := m.Update(msg)
+		m = model.(Model)
+	}
+	return m
+}
+
+// open presses enter and sends the message of the selected item, as the program would once its command ran
+func open(t *testing.T, m Model) Model {
+	t.Helper()
+	model, cmd := m.Update(press(tea.KeyEnter))
+	m = model.(Model)
+	assert.NotZero(t, cmd, "nothing to open")
+	return update(t, m, cmd())
+}
+
+// quits returns whether a key quits the Model, views are shared so any other effect of the key is kept
+func quits(m Model, msg tea.KeyMsg) bool {
+	_, cmd := m.Update(msg)
+	if cmd == nil {
+		return false
+	}
+	_, ok := cmd().(tea.QuitMsg)
+	return ok
+}
+
+func press(k tea.KeyType) tea.KeyMsg {
+	return tea.KeyMsg{Type: k}
+}
+
+func runes(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+// titles returns the breadcrumb of the Model
+func titles(m Model) []string {
+	t := make([]string, 0, len(m.stack))
+	for _, v := range m.stack {
+		t = append(t, v.Title())
+	}
+	return t
+}
+
+func TestNavigation(t *testing.T) {
+	m := newTestModel(t)
+	assert.Equal(t, []string{"repos"}, titles(m))
+
+	// Back does nothing at the root
+	m = update(t, m, press(tea.KeyEsc))
+	assert.Equal(t, []string{"repos"}, titles(m))
+
+	m = update(t, m, press(tea.KeyDown))
+	m = open(t, m)
+	assert.Equal(t, []string{"repos", "beta@main"}, titles(m))
+	repo := m.top().(*repoView)
+	assert.Equal(t, "files", tabNames[repo.active])
+
+	// Tabs wrap around in both directions, the search input has to be left with esc first
+	m = update(t, m, press(tea.KeyShiftTab))
+	assert.Equal(t, "search", tabNames[repo.active])
+	m = update(t, m, press(tea.KeyTab))
+	assert.Equal(t, "search", tabNames[repo.active])
+	m = update(t, m, press(tea.KeyEsc))
+	assert.Equal(t, []string{"repos", "beta@main"}, titles(m))
+	m = update(t, m, press(tea.KeyTab), press(tea.KeyTab))
+	assert.Equal(t, "log", tabNames[repo.active])
+
+	m = open(t, m)
+	assert.Equal(t, 3, len(m.stack))
+	_, ok := m.top().(*textView)
+	assert.True(t, ok)
+	assert.Contains(t, m.View(), "second")
+
+	m = update(t, m, press(tea.KeyEsc))
+	assert.Equal(t, []string{"repos", "beta@main"}, titles(m))
+	assert.Equal(t, "log", tabNames[repo.active])
+
+	// Files open into directories and files
+	m = update(t, m, press(tea.KeyShiftTab))
+	assert.Equal(t, "files", tabNames[repo.active])
+	m = update(t, m, press(tea.KeyDown))
+	m = open(t, m)
+	assert.Equal(t, []string{"repos", "beta@main", "main.go"}, titles(m))
+	assert.Contains(t, m.View(), "func")
+
+	m = update(t, m, press(tea.KeyEsc), press(tea.KeyEsc))
+	assert.Equal(t, []string{"repos"}, titles(m))
+}
+
+func TestRefs(t *testing.T) {
+	m := newTestModel(t)
+	m = update(t, m, press(tea.KeyDown))
+	m = open(t, m)
+
+	// Switching to a tag reloads the repo view at that ref, back on the files tab
+	m = update(t, m, press(tea.KeyTab), press(tea.KeyTab), press(tea.KeyTab))
+	assert.Equal(t, "tags", tabNames[m.top().(*repoView).active])
+	m = open(t, m)
+	assert.Equal(t, []string{"repos", "beta@v1"}, titles(m))
+	assert.Equal(t, "files", tabNames[m.top().(*repoView).active])
+	assert.NotContains(t, m.View(), "main.go")
+
+	m = update(t, m, press(tea.KeyTab), press(tea.KeyTab))
+	assert.Equal(t, "branches", tabNames[m.top().(*repoView).active])
+	m = open(t, m)
+	assert.Equal(t, []string{"repos", "beta@main"}, titles(m))
+	assert.Contains(t, m.View(), "main.go")
+}
+
+func TestSearch(t *testing.T) {
+	m := newTestModel(t)
+	m = update(t, m, press(tea.KeyDown))
+	m = open(t, m)
+	m = update(t, m, press(tea.KeyShiftTab))
+	assert.True(t, m.top().Capturing())
+
+	// q goes to the search input while it is focused
+	assert.False(t, quits(m, runes("q")))
+	m = update(t, m, press(tea.KeyBackspace), runes("func"))
+	search := m.top().(*repoView).tabs[m.top().(*repoView).active].(*searchView)
+	assert.Equal(t, "func", search.input.Value())
+
+	m = update(t, m, press(tea.KeyEnter))
+	assert.False(t, m.top().Capturing())
+	assert.Contains(t, m.View(), "1 results")
+	assert.Contains(t, m.View(), "main.go:3")
+
+	m = open(t, m)
+	assert.Equal(t, []string{"repos", "beta@main", "main.go"}, titles(m))
+	m = update(t, m, press(tea.KeyEsc))
+
+	// / refocuses the input, esc blurs it before it goes back
+	m = update(t, m, runes("/"))
+	assert.True(t, m.top().Capturing())
+	m = update(t, m, press(tea.KeyEsc))
+	assert.False(t, m.top().Capturing())
+	assert.Equal(t, []string{"repos", "beta@main"}, titles(m))
+	assert.True(t, quits(m, runes("q")))
+	m = update(t, m, press(tea.KeyEsc))
+	assert.Equal(t, []string{"repos"}, titles(m))
+}
+
+func TestFilter(t *testing.T) {
+	m := newTestModel(t)
+	m = update(t, m, runes("/"))
+	assert.True(t, m.top().Capturing())
+
+	// While filtering q is typed rather than quitting, but ctrl+c always quits
+	assert.False(t, quits(m, runes("q")))
+	assert.True(t, quits(m, press(tea.KeyCtrlC)))
+	m = update(t, m, press(tea.KeyBackspace))
+
+	// An applied filter is cleared with esc before q quits
+	m = update(t, m, runes("be"), press(tea.KeyEnter))
+	assert.Equal(t, "be", m.top().(*listView).list.FilterValue())
+	assert.True(t, m.top().Capturing())
+	assert.False(t, quits(m, runes("q")))
+	m = update(t, m, press(tea.KeyEsc))
+	assert.Equal(t, "", m.top().(*listView).list.FilterValue())
+	assert.False(t, m.top().Capturing())
+	assert.True(t, quits(m, runes("q")))
+
+	// The same goes for esc in a view further down the stack, which clears the filter before going back
+	m = update(t, m, press(tea.KeyDown))
+	m = open(t, m)
+	m = update(t, m, runes("/"), runes("main"), press(tea.KeyEnter), press(tea.KeyEsc))
+	assert.Equal(t, []string{"repos", "beta@main"}, titles(m))
+	assert.False(t, m.top().Capturing())
+	m = update(t, m, press(tea.KeyEsc))
+	assert.Equal(t, []string{"repos"}, titles(m))
+}
+
+func TestErr(t *testing.T) {
+	m := newTestModel(t)
+	m = update(t, m, errMsg{err: errors.New("could not open repo")})
+	assert.Contains(t, m.View(), "could not open repo")
+	assert.Equal(t, []string{"repos"}, titles(m))
+
+	// The error is cleared by the next key press
+	m = update(t, m, press(tea.KeyDown))
+	assert.NotContains(t, m.View(), "could not open repo")
+}